@@ -8,12 +8,12 @@ import (
 	"os"
 	"os/signal"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/ystyle/xiaoyi-agent-sdk/pkg/client"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/router"
 	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
 )
 
@@ -28,6 +28,9 @@ func main() {
 		SK:           os.Getenv("XIAOYI_SK"),
 		AgentID:      os.Getenv("XIAOYI_AGENT_ID"),
 		SingleServer: true,
+
+		MaxConcurrentHandlers: 8,
+		PerSessionSerial:      true,
 	}
 
 	if cfg.AK == "" || cfg.SK == "" || cfg.AgentID == "" {
@@ -37,56 +40,62 @@ func main() {
 
 	c := client.New(cfg)
 
-	var (
-		sessionTaskIDs   = make(map[string]string)
-		sessionTaskIDsMu sync.Mutex
-	)
-
-	c.OnMessage(func(ctx context.Context, msg types.Message) error {
-		sessionID := msg.SessionID()
-		taskID := msg.TaskID()
-		text := strings.TrimSpace(msg.Text())
+	r := router.New(c)
 
-		slog.Info("收到消息", "session", sessionID, "task", taskID, "text", text)
+	r.Command("/long", func(ctx context.Context, req *router.Request) error {
+		sessionID := req.SessionID()
+		taskID := req.TaskID()
+		delay := time.Duration(rand.Intn(30)+1) * time.Second
+		slog.Info("长任务开始", "delay", delay)
 
-		for _, p := range msg.Parts() {
-			if f, ok := p.(*types.FilePart); ok {
-				slog.Info("收到文件", "name", f.Name(), "mime", f.MimeType(), "uri", f.URI())
-			}
+		if err := c.SendStatus(ctx, taskID, sessionID, fmt.Sprintf("处理中，预计 %v 后完成", delay), "working"); err != nil {
+			return err
 		}
 
-		sessionTaskIDsMu.Lock()
-		sessionTaskIDs[sessionID] = taskID
-		sessionTaskIDsMu.Unlock()
-
-		if strings.HasPrefix(text, "/long") {
-			delay := time.Duration(rand.Intn(30)+1) * time.Second
-			slog.Info("长任务开始", "delay", delay)
-
-			go func() {
-				time.Sleep(delay)
-				reply := fmt.Sprintf("长任务完成 (延迟 %v)", delay)
-				if err := c.ReplyStream(context.Background(), taskID, sessionID, reply, false, false); err != nil {
-					slog.Error("长任务发送失败", "error", err)
-					return
-				}
-				if err := c.SendStatus(context.Background(), taskID, sessionID, "已完成", "completed"); err != nil {
-					slog.Error("发送完成状态失败", "error", err)
+		// 分离后处理器可以立即返回，ctx 保持有效直到任务结束，tasks/cancel 会取消它
+		task := c.Task(req)
+		task.Detach()
+		go func() {
+			select {
+			case <-ctx.Done():
+				slog.Info("长任务已取消", "task", taskID, "cause", context.Cause(ctx))
+				if err := task.Cancel(context.WithoutCancel(ctx), "已取消"); err != nil {
+					slog.Error("发送取消状态失败", "error", err)
 				}
-				slog.Info("长任务已响应", "delay", delay)
-			}()
-			return c.SendStatus(ctx, taskID, sessionID, fmt.Sprintf("处理中，预计 %v 后完成", delay), "working")
+				return
+			case <-time.After(delay):
+			}
+
+			reply := fmt.Sprintf("长任务完成 (延迟 %v)", delay)
+			if err := c.ReplyStream(ctx, taskID, sessionID, reply, false, false); err != nil {
+				slog.Error("长任务发送失败", "error", err)
+				return
+			}
+			if err := c.SendStatus(ctx, taskID, sessionID, "已完成", "completed"); err != nil {
+				slog.Error("发送完成状态失败", "error", err)
+			}
+			slog.Info("长任务已响应", "delay", delay)
+		}()
+		return nil
+	}, router.Alias("/l"), router.Description("随机延迟后回复"))
+
+	r.OnFile(func(ctx context.Context, req *router.Request) error {
+		for _, f := range req.Files() {
+			slog.Info("收到文件", "name", f.Name(), "mime", f.MimeType(), "uri", f.URI())
 		}
+		return c.Reply(ctx, req.TaskID(), req.SessionID(), fmt.Sprintf("收到 %d 个文件", len(req.Files())))
+	})
 
-		reply := fmt.Sprintf("Echo: %s", text)
-		return c.Reply(ctx, taskID, sessionID, reply)
+	r.Fallback(func(ctx context.Context, req *router.Request) error {
+		text := strings.TrimSpace(req.Text())
+		slog.Info("收到消息", "session", req.SessionID(), "task", req.TaskID(), "text", text)
+		return c.Reply(ctx, req.TaskID(), req.SessionID(), fmt.Sprintf("Echo: %s", text))
 	})
 
+	c.OnMessage(r.Handle)
+
 	c.OnClear(func(sessionID string) {
 		slog.Info("清理会话", "session", sessionID)
-		sessionTaskIDsMu.Lock()
-		delete(sessionTaskIDs, sessionID)
-		sessionTaskIDsMu.Unlock()
 	})
 
 	c.OnCancel(func(sessionID, taskID string) {
@@ -104,7 +113,7 @@ func main() {
 	}
 	defer c.Close()
 
-	slog.Info("已连接", "commands", "任意消息->Echo回复, /long->随机延迟回复")
+	slog.Info("已连接", "commands", "任意消息->Echo回复, /long->随机延迟回复, /help->命令列表")
 	slog.Info("等待消息...")
 
 	sigCh := make(chan os.Signal, 1)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
@@ -56,6 +57,10 @@ func BuildResponseMessage(agentID, sessionID, taskID string, jsonRpcResponse *ty
 }
 
 func BuildArtifactResponse(messageID, taskID string, parts []types.Part, isFinal, append bool) *types.JsonRpcResponse {
+	return BuildArtifactChunk(messageID, taskID, GenerateID(), parts, append, isFinal, isFinal)
+}
+
+func BuildArtifactChunk(messageID, taskID, artifactID string, parts []types.Part, append, lastChunk, final bool) *types.JsonRpcResponse {
 	return &types.JsonRpcResponse{
 		JSONRPC: "2.0",
 		ID:      messageID,
@@ -63,10 +68,10 @@ func BuildArtifactResponse(messageID, taskID string, parts []types.Part, isFinal
 			TaskID:    taskID,
 			Kind:      "artifact-update",
 			Append:    append,
-			LastChunk: isFinal,
-			Final:     isFinal,
+			LastChunk: lastChunk,
+			Final:     final,
 			Artifact: types.ArtifactPayload{
-				ArtifactID: GenerateID(),
+				ArtifactID: artifactID,
 				Parts:      parts,
 			},
 		},
@@ -83,7 +88,7 @@ func BuildStatusResponse(messageID, taskID, message string, state string) *types
 		Result: &types.StatusUpdate{
 			TaskID: taskID,
 			Kind:   "status-update",
-			Final:  state == "completed",
+			Final:  types.TaskState(state).IsFinal(),
 			Status: types.StatusPayload{
 				Message: types.MessageBody{
 					Role: "agent",
@@ -97,7 +102,7 @@ func BuildStatusResponse(messageID, taskID, message string, state string) *types
 	}
 }
 
-func BuildErrorResponse(messageID, code, message string) *types.JsonRpcResponse {
+func BuildErrorResponse(messageID string, code any, message string) *types.JsonRpcResponse {
 	return &types.JsonRpcResponse{
 		JSONRPC: "2.0",
 		ID:      messageID,
@@ -124,7 +129,7 @@ func BuildClearContextResponse(requestID string, success bool) *types.JsonRpcRes
 	}
 }
 
-func BuildTasksCancelResponse(requestID string, success bool) *types.JsonRpcResponse {
+func BuildTasksCancelResponse(requestID, taskID string, success bool) *types.JsonRpcResponse {
 	state := "canceled"
 	if !success {
 		state = "failed"
@@ -133,7 +138,7 @@ func BuildTasksCancelResponse(requestID string, success bool) *types.JsonRpcResp
 		JSONRPC: "2.0",
 		ID:      requestID,
 		Result: &types.TasksCancelResult{
-			ID: requestID,
+			ID: taskID,
 			Status: struct {
 				State string `json:"state"`
 			}{State: state},
@@ -163,7 +168,12 @@ func BuildPushResponse(messageID, taskID, pushText string, parts []types.Part) *
 	}
 }
 
-func ParseA2ARequest(data []byte) (*types.A2ARequest, error) {
+var (
+	requestFields = []string{"jsonrpc", "id", "method", "agentId", "deviceId", "conversationId", "sessionId", "params"}
+	paramsFields  = []string{"id", "sessionId", "agentLoginSessionId", "message"}
+)
+
+func ParseA2ARequest(data []byte) (*types.A2ARequest, []types.ParseWarning, error) {
 	var raw struct {
 		JSONRPC        string          `json:"jsonrpc"`
 		ID             string          `json:"id"`
@@ -175,12 +185,32 @@ func ParseA2ARequest(data []byte) (*types.A2ARequest, error) {
 		Params         json.RawMessage `json:"params"`
 	}
 	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	params, err := parseRequestParams(raw.Params)
+	var warnings []types.ParseWarning
+	params, err := parseRequestParams(raw.Params, func(w types.ParseWarning) {
+		warnings = append(warnings, w)
+	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	metadata, err := unknownFields(data, requestFields, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(raw.Params) > 0 {
+		extra, err := unknownFields(raw.Params, paramsFields, "params.")
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range extra {
+			if metadata == nil {
+				metadata = make(map[string]json.RawMessage)
+			}
+			metadata[k] = v
+		}
 	}
 
 	return &types.A2ARequest{
@@ -192,10 +222,33 @@ func ParseA2ARequest(data []byte) (*types.A2ARequest, error) {
 		ConversationID: raw.ConversationID,
 		SessionIDField: raw.SessionID,
 		Params:         *params,
-	}, nil
+		ReceivedAt:     time.Now(),
+		Metadata:       metadata,
+	}, warnings, nil
+}
+
+func unknownFields(data []byte, known []string, prefix string) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, k := range known {
+		delete(fields, k)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		out[prefix+k] = v
+	}
+	return out, nil
 }
 
-func parseRequestParams(data json.RawMessage) (*types.RequestParams, error) {
+func parseRequestParams(data json.RawMessage, warn func(types.ParseWarning)) (*types.RequestParams, error) {
+	if len(data) == 0 {
+		return &types.RequestParams{}, nil
+	}
 	var raw struct {
 		ID                  string          `json:"id"`
 		SessionID           string          `json:"sessionId,omitempty"`
@@ -206,7 +259,7 @@ func parseRequestParams(data json.RawMessage) (*types.RequestParams, error) {
 		return nil, err
 	}
 
-	message, err := parseMessageBody(raw.Message)
+	message, err := parseMessageBody(raw.Message, warn)
 	if err != nil {
 		return nil, err
 	}
@@ -219,7 +272,10 @@ func parseRequestParams(data json.RawMessage) (*types.RequestParams, error) {
 	}, nil
 }
 
-func parseMessageBody(data json.RawMessage) (*types.MessageBody, error) {
+func parseMessageBody(data json.RawMessage, warn func(types.ParseWarning)) (*types.MessageBody, error) {
+	if len(data) == 0 {
+		return &types.MessageBody{}, nil
+	}
 	var raw struct {
 		Kind      string          `json:"kind,omitempty"`
 		MessageID string          `json:"messageId,omitempty"`
@@ -230,7 +286,7 @@ func parseMessageBody(data json.RawMessage) (*types.MessageBody, error) {
 		return nil, err
 	}
 
-	parts, err := parseParts(raw.Parts)
+	parts, err := parseParts(raw.Parts, warn)
 	if err != nil {
 		return nil, err
 	}
@@ -243,56 +299,257 @@ func parseMessageBody(data json.RawMessage) (*types.MessageBody, error) {
 	}, nil
 }
 
-func parseParts(data json.RawMessage) ([]types.Part, error) {
+var (
+	partDecodersMu sync.RWMutex
+	partDecoders   = make(map[string]types.PartDecoder)
+)
+
+// RegisterPartKind 为新的 part kind 注册解码器，text/file/data 始终使用内置解码
+func RegisterPartKind(kind string, decoder types.PartDecoder) {
+	partDecodersMu.Lock()
+	defer partDecodersMu.Unlock()
+	if decoder == nil {
+		delete(partDecoders, kind)
+		return
+	}
+	partDecoders[kind] = decoder
+}
+
+func lookupPartDecoder(kind string) types.PartDecoder {
+	partDecodersMu.RLock()
+	defer partDecodersMu.RUnlock()
+	return partDecoders[kind]
+}
+
+func ParseParts(data json.RawMessage) ([]types.Part, error) {
+	return parseParts(data, nil)
+}
+
+func parseParts(data json.RawMessage, warn func(types.ParseWarning)) ([]types.Part, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
 	var rawParts []json.RawMessage
 	if err := json.Unmarshal(data, &rawParts); err != nil {
 		return nil, err
 	}
 
 	parts := make([]types.Part, 0, len(rawParts))
-	for _, rp := range rawParts {
-		part, err := parsePart(rp)
-		if err != nil {
-			continue
+	for i, rp := range rawParts {
+		part, kind, err := parsePart(rp)
+		if err != nil && warn != nil {
+			warn(types.ParseWarning{Index: i, Kind: kind, Raw: rp, Err: err, Dropped: part == nil})
+		}
+		if part != nil {
+			parts = append(parts, part)
 		}
-		parts = append(parts, part)
 	}
 	return parts, nil
 }
 
-func parsePart(data json.RawMessage) (types.Part, error) {
+// parsePart 解码单个 part；自定义解码失败时仍返回 UnknownPart 并附带错误
+func parsePart(data json.RawMessage) (types.Part, string, error) {
 	var kind struct {
 		Kind string `json:"kind"`
 	}
 	if err := json.Unmarshal(data, &kind); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	switch kind.Kind {
+	case "":
+		return nil, "", fmt.Errorf("part kind is missing")
 	case "text":
 		var tp struct {
 			Text string `json:"text"`
 		}
 		if err := json.Unmarshal(data, &tp); err != nil {
-			return nil, err
+			return nil, kind.Kind, err
 		}
-		return types.NewTextPart(tp.Text), nil
+		return types.NewTextPart(tp.Text), kind.Kind, nil
 	case "file":
 		var fp struct {
 			File types.File `json:"file"`
 		}
 		if err := json.Unmarshal(data, &fp); err != nil {
-			return nil, err
+			return nil, kind.Kind, err
 		}
-		return types.NewFilePart(fp.File.Name, fp.File.MimeType, fp.File.URI, fp.File.Bytes), nil
+		return types.NewFilePart(fp.File.Name, fp.File.MimeType, fp.File.URI, fp.File.Bytes), kind.Kind, nil
 	case "data":
 		var dp struct {
 			Data any `json:"data"`
 		}
 		if err := json.Unmarshal(data, &dp); err != nil {
+			return nil, kind.Kind, err
+		}
+		return types.NewDataPart(dp.Data), kind.Kind, nil
+	}
+
+	unknown := &types.UnknownPart{KindField: kind.Kind, Raw: append(json.RawMessage(nil), data...)}
+	decoder := lookupPartDecoder(kind.Kind)
+	if decoder == nil {
+		return unknown, kind.Kind, nil
+	}
+	part, err := decoder(data)
+	if err != nil || part == nil {
+		if err == nil {
+			err = fmt.Errorf("decoder returned nil part")
+		}
+		return unknown, kind.Kind, err
+	}
+	return part, kind.Kind, nil
+}
+
+func DecodeArtifactUpdate(data json.RawMessage) (*types.ArtifactUpdate, error) {
+	var raw struct {
+		TaskID    string `json:"taskId"`
+		Kind      string `json:"kind"`
+		Append    bool   `json:"append"`
+		LastChunk bool   `json:"lastChunk"`
+		Final     bool   `json:"final"`
+		Artifact  struct {
+			ArtifactID string          `json:"artifactId"`
+			Parts      json.RawMessage `json:"parts"`
+		} `json:"artifact"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	parts, err := ParseParts(raw.Artifact.Parts)
+	if err != nil {
+		return nil, err
+	}
+	return &types.ArtifactUpdate{
+		TaskID:    raw.TaskID,
+		Kind:      raw.Kind,
+		Append:    raw.Append,
+		LastChunk: raw.LastChunk,
+		Final:     raw.Final,
+		Artifact: types.ArtifactPayload{
+			ArtifactID: raw.Artifact.ArtifactID,
+			Parts:      parts,
+		},
+	}, nil
+}
+
+func DecodeStatusUpdate(data json.RawMessage) (*types.StatusUpdate, error) {
+	var raw struct {
+		TaskID string `json:"taskId"`
+		Kind   string `json:"kind"`
+		Final  bool   `json:"final"`
+		Status struct {
+			Message struct {
+				Role  string          `json:"role"`
+				Parts json.RawMessage `json:"parts"`
+			} `json:"message"`
+			State string `json:"state"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	parts, err := ParseParts(raw.Status.Message.Parts)
+	if err != nil {
+		return nil, err
+	}
+	return &types.StatusUpdate{
+		TaskID: raw.TaskID,
+		Kind:   raw.Kind,
+		Final:  raw.Final,
+		Status: types.StatusPayload{
+			Message: types.MessageBody{
+				Role:  raw.Status.Message.Role,
+				Parts: parts,
+			},
+			State: raw.Status.State,
+		},
+	}, nil
+}
+
+func DecodePushUpdate(data json.RawMessage) (*types.PushUpdate, error) {
+	var raw struct {
+		ID        string `json:"id"`
+		PushID    string `json:"pushId"`
+		PushText  string `json:"pushText"`
+		Kind      string `json:"kind"`
+		Artifacts []struct {
+			ArtifactID string          `json:"artifactId"`
+			Parts      json.RawMessage `json:"parts"`
+		} `json:"artifacts"`
+		Status types.PushStatus `json:"status"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	push := &types.PushUpdate{
+		ID:       raw.ID,
+		PushID:   raw.PushID,
+		PushText: raw.PushText,
+		Kind:     raw.Kind,
+		Status:   raw.Status,
+	}
+	for _, a := range raw.Artifacts {
+		parts, err := ParseParts(a.Parts)
+		if err != nil {
+			return nil, err
+		}
+		push.Artifacts = append(push.Artifacts, types.PushArtifact{
+			ArtifactID: a.ArtifactID,
+			Parts:      parts,
+		})
+	}
+	return push, nil
+}
+
+// DecodeTaskResult 解码 tasks/get 的结果，状态消息与 artifact 中的 part 按 kind 解析
+func DecodeTaskResult(data json.RawMessage) (*types.TaskResult, error) {
+	var raw struct {
+		ID        string `json:"id"`
+		ContextID string `json:"contextId"`
+		Kind      string `json:"kind"`
+		Status    struct {
+			State   types.TaskState `json:"state"`
+			Message *struct {
+				Kind      string          `json:"kind"`
+				MessageID string          `json:"messageId"`
+				Role      string          `json:"role"`
+				Parts     json.RawMessage `json:"parts"`
+			} `json:"message"`
+			Timestamp string `json:"timestamp"`
+		} `json:"status"`
+		Artifacts []struct {
+			ArtifactID string          `json:"artifactId"`
+			Parts      json.RawMessage `json:"parts"`
+		} `json:"artifacts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	result := &types.TaskResult{
+		ID:        raw.ID,
+		ContextID: raw.ContextID,
+		Kind:      raw.Kind,
+		Status: types.TaskResultStatus{
+			State:     raw.Status.State,
+			Timestamp: raw.Status.Timestamp,
+		},
+	}
+	if m := raw.Status.Message; m != nil {
+		parts, err := ParseParts(m.Parts)
+		if err != nil {
+			return nil, err
+		}
+		result.Status.Message = &types.MessageBody{Kind: m.Kind, MessageID: m.MessageID, Role: m.Role, Parts: parts}
+	}
+	for _, a := range raw.Artifacts {
+		parts, err := ParseParts(a.Parts)
+		if err != nil {
 			return nil, err
 		}
-		return types.NewDataPart(dp.Data), nil
+		result.Artifacts = append(result.Artifacts, types.ArtifactPayload{
+			ArtifactID: a.ArtifactID,
+			Parts:      parts,
+		})
 	}
-	return nil, fmt.Errorf("unknown part kind: %s", kind.Kind)
+	return result, nil
 }
@@ -0,0 +1,97 @@
+package protocol
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// SplitArtifact 把编码后超过 maxBytes 的 artifact 回复拆成同一 artifactId 下的追加分片：
+// 文本 part 按字符边界切分，其他 part 单独成片；size 返回回复封装成帧后的字节数。
+// 非 artifact 回复以及无法再拆分的单个 part 原样返回，由调用方决定是否拒绝
+func SplitArtifact(resp *types.JsonRpcResponse, maxBytes int, size func(*types.JsonRpcResponse) int) []*types.JsonRpcResponse {
+	au, ok := resp.Result.(*types.ArtifactUpdate)
+	if !ok || maxBytes <= 0 || size(resp) <= maxBytes {
+		return []*types.JsonRpcResponse{resp}
+	}
+
+	chunk := func(parts []types.Part) *types.JsonRpcResponse {
+		return BuildArtifactChunk(resp.ID, au.TaskID, au.Artifact.ArtifactID, parts, au.Append, au.LastChunk, au.Final)
+	}
+	fits := func(parts []types.Part) bool {
+		return size(chunk(parts)) <= maxBytes
+	}
+
+	var chunks [][]types.Part
+	var current []types.Part
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, current)
+			current = nil
+		}
+	}
+	for _, p := range au.Artifact.Parts {
+		if fits(append(current[:len(current):len(current)], p)) {
+			current = append(current, p)
+			continue
+		}
+		tp, ok := p.(*types.TextPart)
+		if !ok {
+			flush()
+			current = []types.Part{p}
+			continue
+		}
+		text := tp.Text
+		for text != "" {
+			n := fitText(text, maxBytes, func(s string) bool {
+				return fits(append(current[:len(current):len(current)], types.NewTextPart(s)))
+			})
+			if n == 0 {
+				if len(current) > 0 {
+					flush()
+					continue
+				}
+				// 单个字符也超出上限时仍然单独成片，避免死循环
+				_, n = utf8.DecodeRuneInString(text)
+			}
+			current = append(current, types.NewTextPart(text[:n]))
+			text = text[n:]
+			if text != "" {
+				flush()
+			}
+		}
+	}
+	flush()
+
+	out := make([]*types.JsonRpcResponse, len(chunks))
+	for i, parts := range chunks {
+		last := i == len(chunks)-1
+		id := resp.ID
+		if i > 0 {
+			id = GenerateID()
+		}
+		out[i] = BuildArtifactChunk(id, au.TaskID, au.Artifact.ArtifactID, parts,
+			au.Append || i > 0, last && au.LastChunk, last && au.Final)
+	}
+	return out
+}
+
+// fitText 返回 text 中满足 fits 的最长前缀的字节数，前缀落在字符边界上；
+// 帧中的文本至少占用其字节数，因此只在前 maxBytes 字节内查找
+func fitText(text string, maxBytes int, fits func(string) bool) int {
+	end := min(len(text), maxBytes)
+	bounds := make([]int, 0, end+1)
+	for i := 0; i <= end; i++ {
+		if i == len(text) || utf8.RuneStart(text[i]) {
+			bounds = append(bounds, i)
+		}
+	}
+	k := sort.Search(len(bounds), func(i int) bool {
+		return !fits(text[:bounds[i]])
+	})
+	if k == 0 {
+		return 0
+	}
+	return bounds[k-1]
+}
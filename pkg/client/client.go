@@ -3,9 +3,11 @@ package client
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/store"
 	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
 	"github.com/ystyle/xiaoyi-agent-sdk/pkg/websocket"
 )
@@ -14,32 +16,63 @@ type Client interface {
 	Connect(ctx context.Context) error
 	Close() error
 	IsReady() bool
+	GetState() *types.ConnectionState
+	Metrics() types.Metrics
+	Reconnect(serverID string) error
 
 	Reply(ctx context.Context, taskID, sessionID, text string) error
 	ReplyStream(ctx context.Context, taskID, sessionID, text string, isFinal, append bool) error
+	OpenStream(ctx context.Context, taskID, sessionID string) (*Stream, error)
+	ReplyParts(ctx context.Context, taskID, sessionID string, parts ...types.Part) error
 	SendStatus(ctx context.Context, taskID, sessionID, message, state string) error
 	SendError(ctx context.Context, taskID, sessionID, code, message string) error
 	Push(ctx context.Context, sessionID, text string) error
+	PushParts(ctx context.Context, sessionID string, parts ...types.Part) error
+
+	Task(msg types.Message) *Task
 
 	OnMessage(handler MessageHandler)
+	Use(mw ...Middleware)
+	OnMethod(name string, handler MethodHandler)
 	OnClear(handler func(sessionID string))
 	OnCancel(handler func(sessionID, taskID string))
 	OnError(handler func(serverID string, err error))
+	OnRoute(handler func(sessionID, bound, delivered string))
+	OnGiveUp(handler func(serverID string, attempts int, lastErr error))
+	OnFailover(handler func(event types.FailoverEvent))
+	OnParseWarning(handler func(serverID string, warning types.ParseWarning))
 }
 
 type MessageHandler func(ctx context.Context, msg types.Message) error
 
+// MethodHandler 处理自定义 JSON-RPC 方法，返回值作为 result 回复
+type MethodHandler func(ctx context.Context, msg types.Message) (any, error)
+
 type client struct {
 	config  *types.Config
 	manager *websocket.Manager
+	tasks   taskTable
+
+	messageHandler MessageHandler
+	middlewares    []Middleware
+	clearHandler   func(sessionID string)
 }
 
 func New(cfg *types.Config) Client {
 	cfg.ApplyDefaults()
-	return &client{
+	if cfg.TaskStore == nil {
+		cfg.TaskStore = store.NewMemoryTaskStore(cfg.TaskRetention, cfg.MaxTaskUpdates)
+	}
+	c := &client{
 		config:  cfg,
 		manager: websocket.NewManager(cfg),
+		tasks:   taskTable{tasks: make(map[string]*Task)},
 	}
+	c.manager.OnMessage(c.handleMessage)
+	c.manager.OnClear(c.handleClear)
+	c.OnMethod("tasks/get", c.handleTasksGet)
+	c.OnMethod("tasks/resubscribe", c.handleTasksResubscribe)
+	return c
 }
 
 func (c *client) Connect(ctx context.Context) error {
@@ -51,6 +84,7 @@ func (c *client) Connect(ctx context.Context) error {
 
 func (c *client) Close() error {
 	c.manager.Close()
+	c.tasks.clear()
 	return nil
 }
 
@@ -58,63 +92,193 @@ func (c *client) IsReady() bool {
 	return c.manager.IsReady()
 }
 
+// ready 在未连接时返回 ErrNotConnected；启用出站队列后帧可以在重连期间排队，不要求连接就绪
+func (c *client) ready() error {
+	if c.IsReady() || c.config.OutboundQueue != nil {
+		return nil
+	}
+	return types.ErrNotConnected
+}
+
+// WaitFlushed 使回复在进入出站队列时阻塞，直到帧被写出、过期或 ctx 结束；默认发送后立即返回
+func WaitFlushed(ctx context.Context) context.Context {
+	return websocket.WithWaitFlushed(ctx)
+}
+
+// GetState 返回整体与各接入点的连接状态
+func (c *client) GetState() *types.ConnectionState {
+	return c.manager.GetState()
+}
+
+// Reconnect 手动重连指定接入点（如 "server1"），重连策略放弃后可用于恢复
+func (c *client) Reconnect(serverID string) error {
+	return c.manager.Reconnect(types.ServerID(serverID))
+}
+
+func (c *client) Metrics() types.Metrics {
+	return c.manager.Metrics()
+}
+
 func (c *client) Reply(ctx context.Context, taskID, sessionID, text string) error {
 	return c.ReplyStream(ctx, taskID, sessionID, text, true, false)
 }
 
 func (c *client) ReplyStream(ctx context.Context, taskID, sessionID, text string, isFinal, append bool) error {
-	if !c.IsReady() {
-		return types.ErrNotConnected
+	if err := c.ready(); err != nil {
+		return err
 	}
 
-	messageID := protocol.GenerateID()
 	parts := []types.Part{types.NewTextPart(text)}
-	resp := protocol.BuildArtifactResponse(messageID, taskID, parts, isFinal, append)
-	return c.manager.SendResponse(taskID, sessionID, resp)
+	return c.tasks.get(c, taskID, sessionID).artifact(ctx, "", parts, isFinal, append)
 }
 
 func (c *client) SendStatus(ctx context.Context, taskID, sessionID, message, state string) error {
-	if !c.IsReady() {
-		return types.ErrNotConnected
+	if err := c.ready(); err != nil {
+		return err
+	}
+	if state == "" {
+		state = string(types.TaskWorking)
 	}
 
-	messageID := protocol.GenerateID()
-	resp := protocol.BuildStatusResponse(messageID, taskID, message, state)
-	return c.manager.SendResponse(taskID, sessionID, resp)
+	return c.tasks.get(c, taskID, sessionID).status(ctx, message, types.TaskState(state))
 }
 
 func (c *client) SendError(ctx context.Context, taskID, sessionID, code, message string) error {
-	if !c.IsReady() {
-		return types.ErrNotConnected
+	if err := c.ready(); err != nil {
+		return err
 	}
 
-	messageID := protocol.GenerateID()
-	resp := protocol.BuildErrorResponse(messageID, code, message)
-	return c.manager.SendResponse(taskID, sessionID, resp)
+	return c.tasks.get(c, taskID, sessionID).Fail(ctx, code, message)
 }
 
 func (c *client) Push(ctx context.Context, sessionID, text string) error {
-	if !c.IsReady() {
-		return types.ErrNotConnected
+	if err := c.ready(); err != nil {
+		return err
 	}
 
 	messageID := protocol.GenerateID()
 	taskID := fmt.Sprintf("push_%s", messageID)
 	parts := []types.Part{types.NewTextPart(text)}
 	resp := protocol.BuildPushResponse(messageID, taskID, text, parts)
-	return c.manager.SendResponse(taskID, sessionID, resp)
+	return c.send(ctx, taskID, sessionID, resp)
 }
 
-func (c *client) OnMessage(handler MessageHandler) {
-	c.manager.OnMessage(func(msg *types.A2ARequest) {
-		if err := handler(context.Background(), msg); err != nil {
-			fmt.Printf("[ERROR] Message handler error: %v\n", err)
+// Task 返回消息对应的任务句柄，同一 taskID 始终返回同一个句柄
+func (c *client) Task(msg types.Message) *Task {
+	return c.tasks.get(c, msg.TaskID(), msg.SessionID())
+}
+
+func (c *client) send(ctx context.Context, taskID, sessionID string, resp *types.JsonRpcResponse) error {
+	if err := c.ready(); err != nil {
+		return err
+	}
+	chunks, err := c.split(taskID, sessionID, resp)
+	if err != nil {
+		return err
+	}
+	for _, r := range chunks {
+		if _, err := c.manager.SendResponseVia(ctx, taskID, sessionID, r); err != nil {
+			return err
 		}
-	})
+		c.record(taskID, sessionID, r)
+	}
+	return nil
+}
+
+// split 按 MaxFrameBytes 把过大的 artifact 回复拆成追加分片；
+// 拆分后仍有分片超出上限（非 artifact 回复或无法拆分的 part）时一帧都不发送，返回 ErrFrameTooLarge
+func (c *client) split(taskID, sessionID string, resp *types.JsonRpcResponse) ([]*types.JsonRpcResponse, error) {
+	if c.config.MaxFrameBytes <= 0 {
+		return []*types.JsonRpcResponse{resp}, nil
+	}
+	size := func(r *types.JsonRpcResponse) int {
+		data, _ := protocol.Marshal(protocol.BuildResponseMessage(c.config.AgentID, sessionID, taskID, r))
+		return len(data)
+	}
+	chunks := protocol.SplitArtifact(resp, c.config.MaxFrameBytes, size)
+	for _, r := range chunks {
+		if n := size(r); n > c.config.MaxFrameBytes {
+			return nil, &types.XiaoYiError{
+				Code:    types.ErrFrameTooLarge.Code,
+				Message: fmt.Sprintf("frame for task %s is %d bytes, limit %d", taskID, n, c.config.MaxFrameBytes),
+			}
+		}
+	}
+	return chunks, nil
+}
+
+func (c *client) OnMessage(handler MessageHandler) {
+	c.messageHandler = handler
+}
+
+func (c *client) Use(mw ...Middleware) {
+	c.middlewares = append(c.middlewares, mw...)
+}
+
+func (c *client) handleMessage(ctx context.Context, msg *types.A2ARequest) {
+	if c.messageHandler == nil {
+		return
+	}
+	if msg.GetMethod() == "message/send" {
+		task := c.Task(msg)
+		task.mu.Lock()
+		task.buffered = true
+		task.mu.Unlock()
+	}
+	err := chain(c.messageHandler, c.middlewares)(ctx, msg)
+	if err != nil {
+		slog.Error("消息处理失败", "session", msg.SessionID(), "task", msg.TaskID(), "error", err)
+	}
+	if msg.TaskID() != "" {
+		c.settle(ctx, c.Task(msg), err)
+	}
+}
+
+// settle 在处理器返回后收尾未分离且未结束的任务：处理器出错时以 failed 结束，否则以 completed 结束并发出缓存的结果；
+// 等待输入、已被服务端取消或收尾发送失败的任务直接移出任务表
+func (c *client) settle(ctx context.Context, task *Task, err error) {
+	task.mu.Lock()
+	state, detached := task.state, task.detached
+	task.mu.Unlock()
+	if detached || state.IsFinal() {
+		return
+	}
+	if state == types.TaskInputRequired || canceledByServer(ctx) {
+		c.tasks.remove(task)
+		return
+	}
+
+	var sendErr error
+	if err != nil {
+		sendErr = failTask(ctx, task, err)
+	} else {
+		sendErr = task.Complete(ctx)
+	}
+	if sendErr != nil {
+		slog.Warn("处理器返回后结束任务失败", "task", task.id, "error", sendErr)
+		c.tasks.remove(task)
+	}
 }
 
 func (c *client) OnClear(handler func(sessionID string)) {
-	c.manager.OnClear(handler)
+	c.clearHandler = handler
+}
+
+func (c *client) OnMethod(name string, handler MethodHandler) {
+	if handler == nil {
+		c.manager.OnMethod(name, nil)
+		return
+	}
+	c.manager.OnMethod(name, func(ctx context.Context, msg *types.A2ARequest) (any, error) {
+		return handler(ctx, msg)
+	})
+}
+
+func (c *client) handleClear(sessionID string) {
+	c.tasks.clearSession(sessionID)
+	if c.clearHandler != nil {
+		c.clearHandler(sessionID)
+	}
 }
 
 func (c *client) OnCancel(handler func(sessionID, taskID string)) {
@@ -127,6 +291,36 @@ func (c *client) OnError(handler func(serverID string, err error)) {
 	})
 }
 
+// OnRoute 在回复因路由策略改由其他服务器送达时回调
+func (c *client) OnRoute(handler func(sessionID, bound, delivered string)) {
+	c.manager.OnRoute(func(sessionID string, bound, delivered types.ServerID) {
+		handler(sessionID, string(bound), string(delivered))
+	})
+}
+
+// OnGiveUp 在重连策略放弃重连时回调，此后需调用 Reconnect 恢复连接
+func (c *client) OnGiveUp(handler func(serverID string, attempts int, lastErr error)) {
+	c.manager.OnGiveUp(func(id types.ServerID, attempts int, lastErr error) {
+		handler(string(id), attempts, lastErr)
+	})
+}
+
+// OnFailover 在主备模式切换或切回接入点时回调
+func (c *client) OnFailover(handler func(event types.FailoverEvent)) {
+	c.manager.OnFailover(websocket.FailoverHandler(handler))
+}
+
+func (c *client) OnParseWarning(handler func(serverID string, warning types.ParseWarning)) {
+	c.manager.OnParseWarning(func(id types.ServerID, w types.ParseWarning) {
+		handler(string(id), w)
+	})
+}
+
+// RegisterPartKind 注册自定义 part kind 的解码器，未注册的 kind 解析为 *types.UnknownPart
+func RegisterPartKind(kind string, decoder types.PartDecoder) {
+	protocol.RegisterPartKind(kind, decoder)
+}
+
 func GenerateMessageID() string {
 	return protocol.GenerateID()
 }
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// Middleware 包装 MessageHandler，先注册的位于最外层
+type Middleware func(MessageHandler) MessageHandler
+
+func chain(h MessageHandler, mws []Middleware) MessageHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Recover 把处理器中的 panic 转换为 ErrHandlerPanic 错误
+func Recover() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg types.Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("消息处理 panic", "task", msg.TaskID(), "panic", r, "stack", string(debug.Stack()))
+					err = &types.XiaoYiError{Code: types.ErrHandlerPanic.Code, Message: fmt.Sprint(r)}
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Logger 记录每条消息的处理耗时与结果，logger 为 nil 时使用 slog.Default()
+func Logger(logger *slog.Logger) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg types.Message) error {
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			start := time.Now()
+			err := next(ctx, msg)
+			attrs := []any{
+				"session", msg.SessionID(),
+				"task", msg.TaskID(),
+				"duration", time.Since(start),
+			}
+			if err != nil {
+				l.ErrorContext(ctx, "消息处理失败", append(attrs, "error", err)...)
+			} else {
+				l.InfoContext(ctx, "消息处理完成", attrs...)
+			}
+			return err
+		}
+	}
+}
+
+// Timeout 限制处理器的执行时间，超时后 ctx 以 ErrHandlerTimeout 为原因取消
+func Timeout(d time.Duration) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg types.Message) error {
+			ctx, cancel := context.WithTimeoutCause(ctx, d, types.ErrHandlerTimeout)
+			defer cancel()
+			err := next(ctx, msg)
+			if err == nil && errors.Is(context.Cause(ctx), types.ErrHandlerTimeout) {
+				return context.Cause(ctx)
+			}
+			return err
+		}
+	}
+}
+
+// ReportErrors 在处理器返回错误且任务尚未结束时，以 failed 结束任务并把错误发给小艺
+func ReportErrors(c Client) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg types.Message) error {
+			err := next(ctx, msg)
+			if err == nil || canceledByServer(ctx) {
+				return err
+			}
+			task := c.Task(msg)
+			if task.State().IsFinal() {
+				return err
+			}
+
+			if sendErr := failTask(context.WithoutCancel(ctx), task, err); sendErr != nil {
+				slog.Warn("发送失败状态失败", "task", msg.TaskID(), "error", sendErr)
+			}
+			return err
+		}
+	}
+}
+
+// failTask 以 err 对应的错误码结束任务，非 *types.XiaoYiError 使用 ErrHandlerFailed
+func failTask(ctx context.Context, task *Task, err error) error {
+	code := types.ErrHandlerFailed.Code
+	message := err.Error()
+	var xe *types.XiaoYiError
+	if errors.As(err, &xe) {
+		code, message = xe.Code, xe.Message
+	}
+	return task.Fail(ctx, code, message)
+}
+
+func canceledByServer(ctx context.Context) bool {
+	cause := context.Cause(ctx)
+	return errors.Is(cause, types.ErrTaskCanceled) ||
+		errors.Is(cause, types.ErrContextCleared) ||
+		errors.Is(cause, types.ErrClientClosed)
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// ReplyParts 以最终 artifact 帧发送任意组合的 text/file/data parts
+func (c *client) ReplyParts(ctx context.Context, taskID, sessionID string, parts ...types.Part) error {
+	if err := c.ready(); err != nil {
+		return err
+	}
+	if err := c.validateParts(parts); err != nil {
+		return err
+	}
+
+	return c.tasks.get(c, taskID, sessionID).artifact(ctx, "", parts, true, false)
+}
+
+func (c *client) PushParts(ctx context.Context, sessionID string, parts ...types.Part) error {
+	if err := c.ready(); err != nil {
+		return err
+	}
+	if err := c.validateParts(parts); err != nil {
+		return err
+	}
+
+	var texts []string
+	for _, p := range parts {
+		if t, ok := p.(*types.TextPart); ok {
+			texts = append(texts, t.Text)
+		}
+	}
+
+	messageID := protocol.GenerateID()
+	taskID := fmt.Sprintf("push_%s", messageID)
+	resp := protocol.BuildPushResponse(messageID, taskID, strings.Join(texts, "\n"), parts)
+	return c.send(ctx, taskID, sessionID, resp)
+}
+
+func (c *client) validateParts(parts []types.Part) error {
+	if len(parts) == 0 {
+		return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: "at least one part is required"}
+	}
+	for i, p := range parts {
+		if err := c.validatePart(p); err != nil {
+			return fmt.Errorf("part %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *client) validatePart(p types.Part) error {
+	switch v := p.(type) {
+	case *types.TextPart:
+		return nil
+	case *types.FilePart:
+		return c.validateFile(&v.File)
+	case *types.DataPart:
+		if _, err := json.Marshal(v.Data); err != nil {
+			return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: "data is not JSON serializable", Err: err}
+		}
+		return nil
+	case nil:
+		return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: "nil part"}
+	}
+	if p.Kind() == "" {
+		return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: "part kind is required"}
+	}
+	if _, err := json.Marshal(p); err != nil {
+		return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: fmt.Sprintf("part kind %q is not JSON serializable", p.Kind()), Err: err}
+	}
+	return nil
+}
+
+func (c *client) validateFile(f *types.File) error {
+	if f.MimeType == "" {
+		return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: "file mimeType is required"}
+	}
+	if _, _, err := mime.ParseMediaType(f.MimeType); err != nil {
+		return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: fmt.Sprintf("invalid mimeType %q", f.MimeType), Err: err}
+	}
+
+	switch {
+	case f.URI == "" && len(f.Bytes) == 0:
+		return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: "file requires uri or bytes"}
+	case f.URI != "" && len(f.Bytes) > 0:
+		return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: "file must not set both uri and bytes"}
+	case f.URI != "":
+		u, err := url.Parse(f.URI)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &types.XiaoYiError{Code: types.ErrInvalidPart.Code, Message: fmt.Sprintf("invalid file uri %q", f.URI), Err: err}
+		}
+	case len(f.Bytes) > c.config.MaxInlineBytes:
+		return &types.XiaoYiError{
+			Code:    types.ErrPartTooLarge.Code,
+			Message: fmt.Sprintf("inline file %q is %d bytes, limit %d", f.Name, len(f.Bytes), c.config.MaxInlineBytes),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/xiaoyitest"
+)
+
+func TestMaxFrameBytes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	const limit = 1024
+	c, srv := newTestClient(t, func(cfg *types.Config) { cfg.MaxFrameBytes = limit })
+	bindSession(t, ctx, c, srv, "s1")
+
+	// artifact 回复拆成多个不超过上限的分片
+	text := strings.Repeat("长文本", 500)
+	if err := c.Reply(ctx, "t1", "s1", text); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool { return r.TaskID == "t1" && r.Final() }); err != nil {
+		t.Fatal(err)
+	}
+	var got strings.Builder
+	frames := srv.ResponsesFor("t1")
+	for _, r := range frames {
+		if len(r.Detail) > limit {
+			t.Fatalf("frame detail is %d bytes, limit %d", len(r.Detail), limit)
+		}
+		got.WriteString(r.Text())
+	}
+	if len(frames) < 2 || got.String() != text {
+		t.Fatalf("got %d frames, text intact = %v", len(frames), got.String() == text)
+	}
+
+	// 状态帧无法拆分，超出上限时返回错误且不发送
+	err := c.SendStatus(ctx, "t2", "s1", strings.Repeat("x", 2*limit), "working")
+	if !errors.Is(err, types.ErrFrameTooLarge) {
+		t.Fatalf("SendStatus err = %v, want ErrFrameTooLarge", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(srv.ResponsesFor("t2")); n != 0 {
+		t.Fatalf("server got %d frames for t2", n)
+	}
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// Stream 将写入的文本作为同一个 artifact 的分片流式发送，Close 时发送 lastChunk/final 帧
+type Stream struct {
+	ctx        context.Context
+	task       *Task
+	artifactID string
+	coalesce   *types.CoalesceConfig
+
+	mu         sync.Mutex
+	pending    []byte
+	buf        strings.Builder
+	timer      *time.Timer
+	lastFrame  time.Time
+	started    bool
+	dataFrames int
+	closed     bool
+	err        error
+	stats      StreamStats
+}
+
+type StreamStats struct {
+	Writes     int // 写入次数
+	FramesSent int // 实际发送的帧数，含最终帧
+	Coalesced  int // 被合并到其他帧中的写入次数
+	BytesSent  int
+}
+
+func (c *client) OpenStream(ctx context.Context, taskID, sessionID string) (*Stream, error) {
+	if err := c.ready(); err != nil {
+		return nil, err
+	}
+
+	task := c.tasks.get(c, taskID, sessionID)
+	if state := task.State(); state.IsFinal() {
+		return nil, &types.TransitionError{TaskID: taskID, From: state, To: state}
+	}
+	return &Stream{
+		ctx:        ctx,
+		task:       task,
+		artifactID: protocol.GenerateID(),
+		coalesce:   c.config.Coalesce,
+	}, nil
+}
+
+func (s *Stream) ArtifactID() string {
+	return s.artifactID
+}
+
+func (s *Stream) Stats() StreamStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Write 发送失败时 p 已留在缓冲中并随下一帧发出，因此仍返回 len(p)，调用方不应重写这部分数据
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, types.ErrStreamClosed
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	s.stats.Writes++
+
+	// 不完整的 UTF-8 字符留到下次写入，避免 io.Copy 在字符中间切分
+	data := append(s.pending, p...)
+	n := completeUTF8(data)
+	s.pending = append([]byte(nil), data[n:]...)
+	s.buf.Write(data[:n])
+
+	if s.coalesce == nil {
+		if s.buf.Len() == 0 {
+			return len(p), nil
+		}
+		if err := s.flush(false); err != nil {
+			return len(p), err
+		}
+		return len(p), nil
+	}
+
+	if err := s.maybeFlush(); err != nil {
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+func (s *Stream) WriteString(text string) (int, error) {
+	return s.Write([]byte(text))
+}
+
+// Close 把缓冲中的剩余文本随最终帧一起发送
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.buf.Write(s.pending)
+	s.pending = nil
+	if err := s.flush(true); err != nil {
+		return err
+	}
+	return s.err
+}
+
+func (s *Stream) maybeFlush() error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+
+	full := s.coalesce.MaxBytes > 0 && s.buf.Len() >= s.coalesce.MaxBytes
+	sentence := s.coalesce.FlushOnSentence && endsSentence(s.buf.String())
+	if (full || sentence) && s.rateWait() == 0 {
+		return s.flush(false)
+	}
+	s.schedule(s.coalesce.Interval)
+	return nil
+}
+
+func (s *Stream) schedule(delay time.Duration) {
+	if s.timer != nil {
+		return
+	}
+	if wait := s.rateWait(); wait > delay {
+		delay = wait
+	}
+	s.timer = time.AfterFunc(delay, s.onTimer)
+}
+
+func (s *Stream) onTimer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timer = nil
+	if s.closed || s.buf.Len() == 0 {
+		return
+	}
+	if wait := s.rateWait(); wait > 0 {
+		s.schedule(wait)
+		return
+	}
+	if err := s.flush(false); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *Stream) rateWait() time.Duration {
+	if s.coalesce == nil || s.coalesce.MaxFramesPerSecond <= 0 || s.lastFrame.IsZero() {
+		return 0
+	}
+	gap := time.Second / time.Duration(s.coalesce.MaxFramesPerSecond)
+	if wait := gap - time.Since(s.lastFrame); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (s *Stream) flush(final bool) error {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	text := s.buf.String()
+	s.buf.Reset()
+
+	parts := []types.Part{types.NewTextPart(text)}
+	if err := s.task.artifact(s.ctx, s.artifactID, parts, final, s.started); err != nil {
+		s.buf.WriteString(text)
+		return err
+	}
+	s.started = true
+	s.lastFrame = time.Now()
+	s.stats.FramesSent++
+	s.stats.BytesSent += len(text)
+	if text != "" {
+		s.dataFrames++
+	}
+	s.stats.Coalesced = max(s.stats.Writes-s.dataFrames, 0)
+	return nil
+}
+
+func endsSentence(text string) bool {
+	r, _ := utf8.DecodeLastRuneInString(text)
+	switch r {
+	case '\n', '.', '!', '?', ';', '。', '！', '？', '；', '…':
+		return true
+	}
+	return false
+}
+
+func completeUTF8(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if utf8.FullRune(data[i:]) {
+			return len(data)
+		}
+		return i
+	}
+	return len(data)
+}
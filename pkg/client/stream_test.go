@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/xiaoyitest"
+)
+
+func TestStreamWriteKeepsBufferedBytes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, srv := newTestClient(t, func(cfg *types.Config) {
+		cfg.ReconnectPolicy = &types.ConstantBackoff{Delay: 10 * time.Millisecond}
+	})
+	bindSession(t, ctx, c, srv, "s1")
+
+	s, err := c.OpenStream(ctx, "t1", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Disconnect()
+	for c.IsReady() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 发送失败时数据已进入缓冲，返回值必须表明 p 已被接收，否则调用方重试会重复发送
+	n, err := s.WriteString("hello")
+	if err == nil || n != len("hello") {
+		t.Fatalf("Write = %d, %v; want %d with error", n, err, len("hello"))
+	}
+
+	for !c.IsReady() {
+		select {
+		case <-ctx.Done():
+			t.Fatal("not reconnected")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool {
+		return r.TaskID == "t1" && r.Final()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Text(); strings.Count(got, "hello") != 1 {
+		t.Fatalf("final frame text = %q, want hello once", got)
+	}
+}
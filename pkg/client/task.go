@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// Task 跟踪单个 A2A 任务的状态，拒绝非法的状态迁移，并保证每个任务只发送一次最终帧
+type Task struct {
+	c         *client
+	id        string
+	sessionID string
+
+	// sendMu 保证同一任务的帧按调用顺序发送；mu 只保护状态，发送期间不持有，State 不会被网络阻塞
+	sendMu sync.Mutex
+	mu     sync.Mutex
+	state  types.TaskState
+	// 非流式请求（message/send）只回复一次：中间 artifact 先缓存，working 状态不发送
+	buffered bool
+	pending  []types.Part
+	// detached 的任务在处理器返回后继续运行，进入终态时才释放 ctx
+	detached bool
+}
+
+func (t *Task) ID() string {
+	return t.id
+}
+
+func (t *Task) SessionID() string {
+	return t.sessionID
+}
+
+func (t *Task) State() types.TaskState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// Detach 使任务在处理器返回后继续运行：处理器收到的 ctx 保持有效直到任务进入终态，
+// 期间 tasks/cancel 会取消它；处理器已返回或任务已被取消时返回 false
+func (t *Task) Detach() bool {
+	if !t.c.manager.DetachTask(t.id) {
+		return false
+	}
+	t.mu.Lock()
+	t.detached = true
+	t.mu.Unlock()
+	return true
+}
+
+func (t *Task) Working(ctx context.Context, message string) error {
+	return t.status(ctx, message, types.TaskWorking)
+}
+
+func (t *Task) RequireInput(ctx context.Context, prompt string) error {
+	return t.status(ctx, prompt, types.TaskInputRequired)
+}
+
+func (t *Task) Cancel(ctx context.Context, message string) error {
+	return t.status(ctx, message, types.TaskCanceled)
+}
+
+// Complete 结束任务，有 parts 时以最终 artifact 帧携带结果，否则发送 completed 状态
+func (t *Task) Complete(ctx context.Context, parts ...types.Part) error {
+	if len(parts) == 0 {
+		return t.status(ctx, "", types.TaskCompleted)
+	}
+	return t.artifact(ctx, "", parts, true, false)
+}
+
+func (t *Task) Fail(ctx context.Context, code, message string) error {
+	return t.transition(ctx, types.TaskFailed, func() *types.JsonRpcResponse {
+		return protocol.BuildErrorResponse(protocol.GenerateID(), code, message)
+	})
+}
+
+func (t *Task) status(ctx context.Context, message string, state types.TaskState) error {
+	return t.transition(ctx, state, func() *types.JsonRpcResponse {
+		if t.buffered {
+			switch {
+			case state == types.TaskWorking:
+				return nil
+			case state == types.TaskCompleted && len(t.pending) > 0:
+				return t.consolidated(nil)
+			case state == types.TaskInputRequired && len(t.pending) > 0:
+				// 等待输入的状态帧即本次请求的回复，缓存的结果随提示一起发出
+				resp := protocol.BuildStatusResponse(protocol.GenerateID(), t.id, message, string(state))
+				body := &resp.Result.(*types.StatusUpdate).Status.Message
+				body.Parts = types.MergeParts(append([]types.Part{}, t.pending...), body.Parts)
+				return resp
+			}
+		}
+		return protocol.BuildStatusResponse(protocol.GenerateID(), t.id, message, string(state))
+	})
+}
+
+func (t *Task) artifact(ctx context.Context, artifactID string, parts []types.Part, isFinal, append bool) error {
+	if artifactID == "" {
+		artifactID = protocol.GenerateID()
+	}
+	if isFinal {
+		return t.transition(ctx, types.TaskCompleted, func() *types.JsonRpcResponse {
+			if t.buffered {
+				return t.consolidated(parts)
+			}
+			return protocol.BuildArtifactChunk(protocol.GenerateID(), t.id, artifactID, parts, append, true, true)
+		})
+	}
+
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	t.mu.Lock()
+	if t.state.IsFinal() {
+		defer t.mu.Unlock()
+		return &types.TransitionError{TaskID: t.id, From: t.state, To: t.state}
+	}
+	if t.buffered {
+		defer t.mu.Unlock()
+		t.pending = types.MergeParts(t.pending, parts)
+		return nil
+	}
+	t.mu.Unlock()
+
+	resp := protocol.BuildArtifactChunk(protocol.GenerateID(), t.id, artifactID, parts, append, false, false)
+	return t.c.send(ctx, t.id, t.sessionID, resp)
+}
+
+// consolidated 把缓存的中间结果与最终 parts 合并为一个最终 artifact 帧
+func (t *Task) consolidated(parts []types.Part) *types.JsonRpcResponse {
+	all := types.MergeParts(append([]types.Part{}, t.pending...), parts)
+	return protocol.BuildArtifactResponse(protocol.GenerateID(), t.id, all, true, false)
+}
+
+// transition 持锁校验迁移并用 build 构造帧，先占用目标状态再在锁外发送，发送失败时恢复原状态；
+// build 返回 nil 表示只更新状态
+func (t *Task) transition(ctx context.Context, to types.TaskState, build func() *types.JsonRpcResponse) error {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+
+	t.mu.Lock()
+	from := t.state
+	if !from.CanTransition(to) {
+		t.mu.Unlock()
+		return &types.TransitionError{TaskID: t.id, From: from, To: to}
+	}
+	resp := build()
+	pending := t.pending
+	t.state = to
+	if to.IsFinal() || resp != nil {
+		t.pending = nil
+	}
+	t.mu.Unlock()
+
+	if resp != nil {
+		if err := t.c.send(ctx, t.id, t.sessionID, resp); err != nil {
+			t.mu.Lock()
+			t.state = from
+			t.pending = pending
+			t.mu.Unlock()
+			return err
+		}
+	}
+	if to.IsFinal() {
+		t.c.tasks.remove(t)
+		t.mu.Lock()
+		detached := t.detached
+		t.mu.Unlock()
+		if detached {
+			t.c.manager.FinishTask(t.id)
+		}
+	}
+	return nil
+}
+
+type taskTable struct {
+	mu    sync.Mutex
+	tasks map[string]*Task
+}
+
+// get 返回进行中的任务；已结束的任务不在表中，按 TaskStore 中的记录恢复为终态，防止重复发送最终帧
+func (tt *taskTable) get(c *client, taskID, sessionID string) *Task {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	if t, ok := tt.tasks[taskID]; ok {
+		return t
+	}
+	if rec, err := c.config.TaskStore.Get(taskID); err == nil && rec.State.IsFinal() {
+		return &Task{c: c, id: taskID, sessionID: rec.SessionID, state: rec.State}
+	}
+	t := &Task{c: c, id: taskID, sessionID: sessionID, state: types.TaskSubmitted}
+	tt.tasks[taskID] = t
+	return t
+}
+
+// remove 在任务结束后把它移出表，之后的查询由 TaskStore 记录兜底
+func (tt *taskTable) remove(t *Task) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	if tt.tasks[t.id] == t {
+		delete(tt.tasks, t.id)
+	}
+}
+
+func (tt *taskTable) clearSession(sessionID string) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	for id, t := range tt.tasks {
+		if t.sessionID == sessionID {
+			delete(tt.tasks, id)
+		}
+	}
+}
+
+func (tt *taskTable) clear() {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.tasks = make(map[string]*Task)
+}
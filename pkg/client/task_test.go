@@ -0,0 +1,304 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/xiaoyitest"
+)
+
+func newTestClient(t *testing.T, setup func(cfg *types.Config)) (*client, *xiaoyitest.Server) {
+	t.Helper()
+	srv := xiaoyitest.NewServer("ak", "sk", "agent")
+	t.Cleanup(srv.Close)
+	cfg := &types.Config{
+		AK: "ak", SK: "sk", AgentID: "agent",
+		SingleServer: true,
+		Transport:    srv.Transport(),
+	}
+	if setup != nil {
+		setup(cfg)
+	}
+	c := New(cfg).(*client)
+	t.Cleanup(func() { c.Close() })
+	return c, srv
+}
+
+// bindSession 让模拟网关发送一条消息，使会话绑定到连接上
+func bindSession(t *testing.T, ctx context.Context, c *client, srv *xiaoyitest.Server, sessionID string) {
+	t.Helper()
+	got := make(chan struct{}, 1)
+	c.OnMessage(func(ctx context.Context, msg types.Message) error {
+		got <- struct{}{}
+		return nil
+	})
+	if err := c.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.SendMessage(sessionID, "bind", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-got:
+	case <-ctx.Done():
+		t.Fatal("message not delivered")
+	}
+	// 处理器返回后绑定消息的任务以 completed 收尾，等它发出后清空记录，不影响后续计数
+	if _, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool { return r.TaskID == "bind" && r.Final() }); err != nil {
+		t.Fatal(err)
+	}
+	srv.Reset()
+	for {
+		c.tasks.mu.Lock()
+		_, ok := c.tasks.tasks["bind"]
+		c.tasks.mu.Unlock()
+		if !ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFinishedTaskLeavesTable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, srv := newTestClient(t, nil)
+	bindSession(t, ctx, c, srv, "s1")
+
+	task := c.tasks.get(c, "t1", "s1")
+	if err := task.Working(ctx, "处理中"); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Complete(ctx, types.NewTextPart("done")); err != nil {
+		t.Fatal(err)
+	}
+
+	c.tasks.mu.Lock()
+	n := len(c.tasks.tasks)
+	c.tasks.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("task table holds %d tasks after completion", n)
+	}
+
+	// 表中已无该任务，仍不能再次发送最终帧
+	err := c.Reply(ctx, "t1", "s1", "again")
+	var te *types.TransitionError
+	if !errors.As(err, &te) || te.From != types.TaskCompleted {
+		t.Fatalf("second reply err = %v, want transition error from completed", err)
+	}
+	if _, err := srv.WaitResponses(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(srv.ResponsesFor("t1")); n != 2 {
+		t.Fatalf("server got %d frames for t1, want 2", n)
+	}
+}
+
+func TestStateNotBlockedBySend(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, srv := newTestClient(t, func(cfg *types.Config) {
+		cfg.ReconnectPolicy = &types.ConstantBackoff{Delay: time.Minute}
+		cfg.OutboundQueue = &types.QueueConfig{MaxAge: time.Minute}
+	})
+	bindSession(t, ctx, c, srv, "s1")
+
+	srv.Disconnect()
+	for c.IsReady() {
+		time.Sleep(time.Millisecond)
+	}
+
+	task := c.tasks.get(c, "t1", "s1")
+	sendCtx, stopSend := context.WithCancel(WaitFlushed(ctx))
+	done := make(chan error, 1)
+	go func() { done <- task.Complete(sendCtx, types.NewTextPart("done")) }()
+
+	// 最终帧在队列中等待重连，此时读取状态不应阻塞
+	deadline := time.After(time.Second)
+	for task.State() != types.TaskCompleted {
+		select {
+		case <-deadline:
+			t.Fatal("State blocked or not updated while the frame is queued")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	stopSend()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Complete err = %v, want context.Canceled", err)
+	}
+}
+
+func TestDetachedTaskCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, srv := newTestClient(t, nil)
+
+	detached := make(chan bool, 1)
+	causes := make(chan error, 1)
+	c.OnMessage(func(taskCtx context.Context, msg types.Message) error {
+		task := c.Task(msg)
+		detached <- task.Detach()
+		go func() {
+			<-taskCtx.Done()
+			causes <- context.Cause(taskCtx)
+			task.Cancel(context.WithoutCancel(taskCtx), "已取消")
+		}()
+		return nil
+	})
+	if err := c.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.SendMessage("s1", "t1", "long"); err != nil {
+		t.Fatal(err)
+	}
+	if !<-detached {
+		t.Fatal("Detach returned false inside the handler")
+	}
+
+	// 处理器已返回，分离的任务仍能被 tasks/cancel 取消
+	if _, err := srv.CancelTask("s1", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool { return r.TasksCancel != nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.TasksCancel.Status.State; got != "canceled" {
+		t.Fatalf("cancel reply state = %q, want canceled", got)
+	}
+	select {
+	case cause := <-causes:
+		if !errors.Is(cause, types.ErrTaskCanceled) {
+			t.Fatalf("cause = %v, want ErrTaskCanceled", cause)
+		}
+	case <-ctx.Done():
+		t.Fatal("detached task ctx not canceled")
+	}
+	if _, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool {
+		return r.TaskID == "t1" && r.Status != nil && r.Status.Status.State == string(types.TaskCanceled)
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandlerReturnSettlesTask(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		handler   func(ctx context.Context, task *Task) error
+		wantState string
+		wantText  string
+		wantError bool
+	}{
+		{
+			name:   "非流式请求返回时发出缓存结果",
+			method: "message/send",
+			handler: func(ctx context.Context, task *Task) error {
+				return task.artifact(ctx, "", []types.Part{types.NewTextPart("部分")}, false, false)
+			},
+			wantText: "部分",
+		},
+		{
+			name:   "非流式请求等待输入时带上缓存结果",
+			method: "message/send",
+			handler: func(ctx context.Context, task *Task) error {
+				if err := task.artifact(ctx, "", []types.Part{types.NewTextPart("部分")}, false, false); err != nil {
+					return err
+				}
+				return task.RequireInput(ctx, "请补充")
+			},
+			wantState: string(types.TaskInputRequired),
+			wantText:  "部分请补充",
+		},
+		{
+			name:   "流式请求返回时补发 completed",
+			method: "message/stream",
+			handler: func(ctx context.Context, task *Task) error {
+				return task.Working(ctx, "处理中")
+			},
+			wantState: string(types.TaskCompleted),
+		},
+		{
+			name:   "处理器出错时以 failed 结束",
+			method: "message/stream",
+			handler: func(ctx context.Context, task *Task) error {
+				return errors.New("boom")
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			c, srv := newTestClient(t, nil)
+			c.OnMessage(func(ctx context.Context, msg types.Message) error {
+				return tt.handler(ctx, c.Task(msg))
+			})
+			if err := c.Connect(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := srv.WaitConnected(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := srv.Send(&xiaoyitest.Request{
+				Method: tt.method, SessionID: "s1", TaskID: "t1",
+				Parts: []types.Part{types.NewTextPart("hi")},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool {
+				return r.TaskID == "t1" && (r.Final() || r.Status != nil && r.Status.Status.State == string(types.TaskInputRequired))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.wantError:
+				if resp.Error == nil {
+					t.Fatalf("got %+v, want error response", resp)
+				}
+			case tt.wantState != "":
+				if resp.Status == nil || resp.Status.Status.State != tt.wantState {
+					t.Fatalf("got %+v, want status %s", resp, tt.wantState)
+				}
+			default:
+				if resp.Artifact == nil || !resp.Artifact.Final {
+					t.Fatalf("got %+v, want final artifact", resp)
+				}
+			}
+			if tt.wantText != "" && resp.Text() != tt.wantText {
+				t.Fatalf("text = %q, want %q", resp.Text(), tt.wantText)
+			}
+
+			// 处理器返回后任务不再留在任务表中
+			deadline := time.After(time.Second)
+			for {
+				c.tasks.mu.Lock()
+				n := len(c.tasks.tasks)
+				c.tasks.mu.Unlock()
+				if n == 0 {
+					break
+				}
+				select {
+				case <-deadline:
+					t.Fatalf("task table holds %d tasks after the handler returned", n)
+				case <-time.After(time.Millisecond):
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// record 把发送成功的任务帧写入 TaskStore，push 与其他结果不记录
+func (c *client) record(taskID, sessionID string, resp *types.JsonRpcResponse) {
+	update := types.TaskUpdate{Error: resp.Error, At: time.Now()}
+	switch r := resp.Result.(type) {
+	case *types.ArtifactUpdate:
+		update.Artifact = r
+	case *types.StatusUpdate:
+		update.Status = r
+	default:
+		if resp.Error == nil {
+			return
+		}
+	}
+	if err := c.config.TaskStore.Append(taskID, sessionID, update); err != nil {
+		slog.Warn("任务记录保存失败", "task", taskID, "error", err)
+	}
+}
+
+func (c *client) lookupTask(taskID string) (*types.TaskRecord, error) {
+	rec, err := c.config.TaskStore.Get(taskID)
+	if errors.Is(err, types.ErrTaskNotFound) {
+		return nil, &types.JsonRpcError{Code: types.RPCTaskNotFound, Message: "Task not found"}
+	}
+	return rec, err
+}
+
+// handleTasksGet 以任务的最新状态和合并后的 artifact 回复 tasks/get
+func (c *client) handleTasksGet(ctx context.Context, msg types.Message) (any, error) {
+	rec, err := c.lookupTask(msg.TaskID())
+	if err != nil {
+		return nil, err
+	}
+	result := &types.TaskResult{
+		ID:        rec.ID,
+		ContextID: rec.SessionID,
+		Kind:      "task",
+		Status: types.TaskResultStatus{
+			State:     rec.State,
+			Timestamp: rec.UpdatedAt.Format(time.RFC3339Nano),
+		},
+		Artifacts: rec.Artifacts(),
+	}
+	if s := rec.LastStatus(); s != nil {
+		result.Status.Message = &s.Status.Message
+	}
+	return result, nil
+}
+
+// handleTasksResubscribe 按原顺序重放任务已发送的全部帧，之后的更新照常发送
+func (c *client) handleTasksResubscribe(ctx context.Context, msg types.Message) (any, error) {
+	rec, err := c.lookupTask(msg.TaskID())
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range rec.Updates {
+		resp := &types.JsonRpcResponse{JSONRPC: "2.0", ID: msg.GetRequestID(), Error: u.Error}
+		switch {
+		case u.Artifact != nil:
+			resp.Result = u.Artifact
+		case u.Status != nil:
+			resp.Result = u.Status
+		}
+		if err := c.manager.SendResponse(rec.ID, rec.SessionID, resp); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
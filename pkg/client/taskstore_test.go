@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/xiaoyitest"
+)
+
+func TestTasksGet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, srv := newTestClient(t, nil)
+	bindSession(t, ctx, c, srv, "s1")
+
+	if err := c.Reply(ctx, "t1", "s1", "答案"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		taskID   string
+		wantText string
+		wantCode any
+	}{
+		{name: "已完成的任务", taskID: "t1", wantText: "答案"},
+		{name: "未知任务", taskID: "missing", wantCode: types.RPCTaskNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := srv.Send(&xiaoyitest.Request{Method: "tasks/get", SessionID: "s1", TaskID: tt.taskID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool { return r.ID == id })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantCode != nil {
+				if resp.Error == nil || fmt.Sprint(resp.Error.Code) != fmt.Sprint(tt.wantCode) {
+					t.Fatalf("error = %+v, want code %v", resp.Error, tt.wantCode)
+				}
+				return
+			}
+			// 结果带 id 字段，需按请求方法识别，不能当成 tasks/cancel 的结果
+			if resp.Task == nil || resp.TasksCancel != nil {
+				t.Fatalf("tasks/get response decoded as %+v", resp)
+			}
+			if resp.Task.ID != tt.taskID || resp.Task.Status.State != types.TaskCompleted {
+				t.Fatalf("task = %+v", resp.Task)
+			}
+			if len(resp.Task.Artifacts) != 1 || len(resp.Task.Artifacts[0].Parts) != 1 {
+				t.Fatalf("artifacts = %+v", resp.Task.Artifacts)
+			}
+			if p, ok := resp.Task.Artifacts[0].Parts[0].(*types.TextPart); !ok || p.Text != tt.wantText {
+				t.Fatalf("artifact part = %#v, want text %q", resp.Task.Artifacts[0].Parts[0], tt.wantText)
+			}
+		})
+	}
+}
+
+func TestTasksResubscribe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, srv := newTestClient(t, nil)
+	bindSession(t, ctx, c, srv, "s1")
+
+	if err := c.SendStatus(ctx, "t1", "s1", "处理中", "working"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reply(ctx, "t1", "s1", "答案"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.WaitResponses(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	srv.Reset()
+
+	// 重放已发送的帧，顺序与首次发送一致
+	if _, err := srv.Send(&xiaoyitest.Request{Method: "tasks/resubscribe", SessionID: "s1", TaskID: "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := srv.WaitResponses(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Status == nil || got[0].Status.Status.State != string(types.TaskWorking) {
+		t.Fatalf("first replayed frame = %+v, want working status", got[0])
+	}
+	if got[1].Artifact == nil || !got[1].Artifact.Final || got[1].Text() != "答案" {
+		t.Fatalf("second replayed frame = %+v, want final artifact", got[1])
+	}
+}
@@ -0,0 +1,317 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+const DefaultMaxSize = 50 << 20
+
+type Options struct {
+	HTTPClient *http.Client
+	MaxSize    int64 // 单个文件的最大字节数，默认 50MB
+}
+
+// Fetcher 读取入站 FilePart 的内容，内联 bytes 与 uri 两种形式统一处理
+type Fetcher struct {
+	client  *http.Client
+	maxSize int64
+}
+
+var Default = New(Options{})
+
+func New(opts Options) *Fetcher {
+	f := &Fetcher{
+		client:  opts.HTTPClient,
+		maxSize: opts.MaxSize,
+	}
+	if f.client == nil {
+		f.client = http.DefaultClient
+	}
+	if f.maxSize <= 0 {
+		f.maxSize = DefaultMaxSize
+	}
+	return f
+}
+
+func Open(ctx context.Context, fp *types.FilePart) (io.ReadCloser, error) {
+	return Default.Open(ctx, fp)
+}
+
+func SaveTo(ctx context.Context, fp *types.FilePart, dir string) (string, error) {
+	return Default.SaveTo(ctx, fp, dir)
+}
+
+func ExtractText(ctx context.Context, fp *types.FilePart) (string, error) {
+	return Default.ExtractText(ctx, fp)
+}
+
+func (f *Fetcher) Open(ctx context.Context, fp *types.FilePart) (io.ReadCloser, error) {
+	if data := fp.Bytes(); len(data) > 0 {
+		if int64(len(data)) > f.maxSize {
+			return nil, f.tooLarge(fp, int64(len(data)))
+		}
+		if err := checkType(fp, http.DetectContentType(data)); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	if fp.URI() == "" {
+		return nil, &types.XiaoYiError{Code: types.ErrFileUnavailable.Code, Message: fmt.Sprintf("file %q has neither bytes nor uri", fp.Name())}
+	}
+	return f.download(ctx, fp)
+}
+
+func (f *Fetcher) download(ctx context.Context, fp *types.FilePart) (io.ReadCloser, error) {
+	u, err := url.Parse(fp.URI())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, &types.XiaoYiError{Code: types.ErrFileUnavailable.Code, Message: fmt.Sprintf("unsupported file uri %q", fp.URI()), Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, &types.XiaoYiError{Code: types.ErrFileUnavailable.Code, Message: "download failed", Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &types.XiaoYiError{Code: types.ErrFileUnavailable.Code, Message: fmt.Sprintf("download failed: %s", resp.Status)}
+	}
+	if resp.ContentLength > f.maxSize {
+		resp.Body.Close()
+		return nil, f.tooLarge(fp, resp.ContentLength)
+	}
+	if err := checkType(fp, resp.Header.Get("Content-Type")); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &limitedBody{body: resp.Body, remaining: f.maxSize, err: f.tooLarge(fp, -1)}, nil
+}
+
+// SaveTo 把文件保存到 dir，文件名经过清理且不会覆盖已有文件，返回最终路径
+func (f *Fetcher) SaveTo(ctx context.Context, fp *types.FilePart, dir string) (string, error) {
+	rc, err := f.Open(ctx, fp)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	path, err := claimPath(dir, SanitizeFilename(fp.Name(), fp.MimeType()))
+	if err != nil {
+		return "", err
+	}
+	// 替换的是刚刚占用的空文件，不会覆盖他人的文件
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// ExtractText 读取 txt/md/json/csv 等文本文件的内容
+func (f *Fetcher) ExtractText(ctx context.Context, fp *types.FilePart) (string, error) {
+	if !IsText(fp) {
+		return "", &types.XiaoYiError{Code: types.ErrFileNotText.Code, Message: fmt.Sprintf("cannot extract text from %q (%s)", fp.Name(), fp.MimeType())}
+	}
+	rc, err := f.Open(ctx, fp)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return "", err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return "", &types.XiaoYiError{Code: types.ErrFileNotText.Code, Message: fmt.Sprintf("%q is not valid UTF-8", fp.Name())}
+	}
+	return string(data), nil
+}
+
+func (f *Fetcher) tooLarge(fp *types.FilePart, size int64) error {
+	if size < 0 {
+		return &types.XiaoYiError{Code: types.ErrFileTooLarge.Code, Message: fmt.Sprintf("file %q exceeds %d bytes", fp.Name(), f.maxSize)}
+	}
+	return &types.XiaoYiError{Code: types.ErrFileTooLarge.Code, Message: fmt.Sprintf("file %q is %d bytes, limit %d", fp.Name(), size, f.maxSize)}
+}
+
+var textTypes = map[string]bool{
+	"text/plain":       true,
+	"text/markdown":    true,
+	"text/x-markdown":  true,
+	"text/csv":         true,
+	"application/json": true,
+}
+
+var textExts = map[string]bool{
+	".txt":  true,
+	".md":   true,
+	".json": true,
+	".csv":  true,
+}
+
+func IsText(fp *types.FilePart) bool {
+	if textTypes[mediaType(fp.MimeType())] {
+		return true
+	}
+	return textExts[strings.ToLower(filepath.Ext(fp.Name()))]
+}
+
+// checkType 比较声明的 mimeType 与实际类型，实际类型未知或为通用二进制类型时放行
+func checkType(fp *types.FilePart, actual string) error {
+	declared := mediaType(fp.MimeType())
+	got := mediaType(actual)
+	if declared == "" || got == "" || got == "application/octet-stream" || declared == got {
+		return nil
+	}
+	if isTextual(declared) && isTextual(got) {
+		return nil
+	}
+	// 内容嗅探只能识别出 zip 容器，docx/xlsx/epub/jar 等格式都显示为 application/zip
+	if got == "application/zip" && isZipBased(declared) {
+		return nil
+	}
+	return &types.XiaoYiError{
+		Code:    types.ErrFileTypeMismatch.Code,
+		Message: fmt.Sprintf("file %q declared %s but content is %s", fp.Name(), declared, got),
+	}
+}
+
+func isTextual(t string) bool {
+	return strings.HasPrefix(t, "text/") || textTypes[t] || strings.HasSuffix(t, "+json") || strings.HasSuffix(t, "/xml")
+}
+
+var zipTypes = map[string]bool{
+	"application/java-archive":                       true,
+	"application/vnd.android.package-archive":        true,
+	"application/x-zip-compressed":                   true,
+	"application/vnd.ms-xpsdocument":                 true,
+	"application/vnd.ms-excel.sheet.macroenabled.12": true,
+}
+
+func isZipBased(t string) bool {
+	return zipTypes[t] ||
+		strings.HasSuffix(t, "+zip") ||
+		strings.HasPrefix(t, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(t, "application/vnd.oasis.opendocument.")
+}
+
+func mediaType(v string) string {
+	t, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return ""
+	}
+	return t
+}
+
+// SanitizeFilename 去掉路径成分与不安全字符，名字为空时按 mimeType 生成默认文件名
+func SanitizeFilename(name, mimeType string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if len(name) > 200 {
+		ext := filepath.Ext(name)
+		if len(ext) > 20 {
+			ext = ""
+		}
+		name = truncateUTF8(name, 200-len(ext)) + ext
+	}
+	if name == "" {
+		name = "file"
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
+
+func truncateUTF8(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// claimPath 以 O_EXCL 创建空文件占用文件名，名字已存在时依次尝试 name-1、name-2…
+func claimPath(dir, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return path, f.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+	}
+}
+
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.body.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, l.err
+	}
+	return n, err
+}
+
+func (l *limitedBody) Close() error {
+	return l.body.Close()
+}
@@ -0,0 +1,188 @@
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+func newFileServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(bytes.Repeat([]byte("x"), 1024))
+	})
+	mux.HandleFunc("/big-chunked", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		for range 4 {
+			w.Write(bytes.Repeat([]byte("x"), 256))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wantCode(t *testing.T, err error, code *types.XiaoYiError) {
+	t.Helper()
+	if !errors.Is(err, code) {
+		t.Fatalf("err = %v, want %s", err, code.Code)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	srv := newFileServer(t)
+	f := New(Options{HTTPClient: srv.Client()})
+
+	text, err := f.ExtractText(context.Background(), types.NewFilePart("hello.txt", "text/plain", srv.URL+"/hello.txt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Fatalf("text = %q", text)
+	}
+
+	_, err = f.Open(context.Background(), types.NewFilePart("missing.txt", "text/plain", srv.URL+"/missing", nil))
+	wantCode(t, err, types.ErrFileUnavailable)
+
+	_, err = f.Open(context.Background(), types.NewFilePart("a.txt", "text/plain", "file:///etc/passwd", nil))
+	wantCode(t, err, types.ErrFileUnavailable)
+}
+
+func TestSizeLimit(t *testing.T) {
+	srv := newFileServer(t)
+	f := New(Options{HTTPClient: srv.Client(), MaxSize: 512})
+	ctx := context.Background()
+
+	_, err := f.Open(ctx, types.NewFilePart("big.bin", "", srv.URL+"/big", nil))
+	wantCode(t, err, types.ErrFileTooLarge)
+
+	// 没有 Content-Length 时在读取过程中截断
+	_, err = f.SaveTo(ctx, types.NewFilePart("big.bin", "", srv.URL+"/big-chunked", nil), t.TempDir())
+	wantCode(t, err, types.ErrFileTooLarge)
+
+	_, err = f.Open(ctx, types.NewFilePart("inline.bin", "", "", bytes.Repeat([]byte("x"), 513)))
+	wantCode(t, err, types.ErrFileTooLarge)
+}
+
+func TestTypeMismatch(t *testing.T) {
+	srv := newFileServer(t)
+	f := New(Options{HTTPClient: srv.Client()})
+	ctx := context.Background()
+
+	_, err := f.Open(ctx, types.NewFilePart("photo.png", "image/png", srv.URL+"/page.html", nil))
+	wantCode(t, err, types.ErrFileTypeMismatch)
+
+	_, err = f.Open(ctx, types.NewFilePart("photo.png", "image/png", "", []byte("<html><body>not an image</body></html>")))
+	wantCode(t, err, types.ErrFileTypeMismatch)
+
+	// 文本类型之间互相兼容
+	if _, err := f.Open(ctx, types.NewFilePart("notes.md", "text/markdown", srv.URL+"/hello.txt", nil)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZipBasedTypes(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte("<w:document/>"))
+	zw.Close()
+
+	ctx := context.Background()
+	for _, mimeType := range []string{
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/epub+zip",
+		"application/java-archive",
+		"application/zip",
+	} {
+		if _, err := Open(ctx, types.NewFilePart("doc", mimeType, "", buf.Bytes())); err != nil {
+			t.Errorf("%s: %v", mimeType, err)
+		}
+	}
+	_, err := Open(ctx, types.NewFilePart("doc.pdf", "application/pdf", "", buf.Bytes()))
+	wantCode(t, err, types.ErrFileTypeMismatch)
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name, mimeType, want string
+	}{
+		{"report.pdf", "", "report.pdf"},
+		{"../../etc/passwd", "", "passwd"},
+		{`..\..\windows\system.ini`, "", "system.ini"},
+		{"a:b*c?.txt", "", "a_b_c_.txt"},
+		{"bad\x00name\n.txt", "", "badname.txt"},
+		{".hidden", "", "hidden"},
+		{"..", "", "file"},
+		{"", "application/json", "file.json"},
+		{"", "", "file"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFilename(tt.name, tt.mimeType); got != tt.want {
+			t.Errorf("SanitizeFilename(%q, %q) = %q, want %q", tt.name, tt.mimeType, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("长", 100) + ".txt"
+	if got := SanitizeFilename(long, ""); len(got) > 200 || !strings.HasSuffix(got, ".txt") {
+		t.Errorf("long name sanitized to %d bytes: %q", len(got), got)
+	}
+}
+
+func TestSaveToDoesNotOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(existing, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 8
+	paths := make([]string, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Go(func() {
+			p, err := SaveTo(context.Background(), types.NewFilePart("a.txt", "text/plain", "", []byte("new")), dir)
+			if err != nil {
+				t.Error(err)
+			}
+			paths[i] = p
+		})
+	}
+	wg.Wait()
+
+	if data, _ := os.ReadFile(existing); string(data) != "keep" {
+		t.Fatalf("existing file overwritten: %q", data)
+	}
+	seen := map[string]bool{existing: true}
+	for _, p := range paths {
+		if seen[p] {
+			t.Fatalf("path %s used twice", p)
+		}
+		seen[p] = true
+		if data, _ := os.ReadFile(p); string(data) != "new" {
+			t.Fatalf("%s = %q", p, data)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package router
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SplitArgs 按空白拆分参数，支持单双引号与反斜杠转义
+func SplitArgs(s string) []string {
+	var (
+		args    []string
+		cur     strings.Builder
+		quote   rune
+		escaped bool
+		inArg   bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{name: "空串", in: "", want: nil},
+		{name: "只有空白", in: "  \t ", want: nil},
+		{name: "按空白拆分", in: "北京  3\t天", want: []string{"北京", "3", "天"}},
+		{name: "双引号", in: `"New York" 3`, want: []string{"New York", "3"}},
+		{name: "单引号", in: `'a b' c`, want: []string{"a b", "c"}},
+		{name: "引号与相邻文本合并", in: `x"a b"y`, want: []string{"xa by"}},
+		{name: "空引号是空参数", in: `"" a`, want: []string{"", "a"}},
+		{name: "反斜杠转义空白", in: `a\ b c`, want: []string{"a b", "c"}},
+		{name: "双引号内转义引号", in: `"say \"hi\""`, want: []string{`say "hi"`}},
+		{name: "单引号内反斜杠按原样保留", in: `'a\b'`, want: []string{`a\b`}},
+		{name: "单引号包含双引号", in: `'"quoted"'`, want: []string{`"quoted"`}},
+		{name: "未闭合的引号取到结尾", in: `"a b`, want: []string{"a b"}},
+		{name: "末尾反斜杠", in: `a\`, want: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitArgs(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("SplitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+var (
+	ErrNoReplier       = &types.XiaoYiError{Code: "NO_REPLIER", Message: "router has no replier"}
+	ErrCommandConflict = &types.XiaoYiError{Code: "COMMAND_CONFLICT", Message: "command name or alias already registered"}
+)
+
+// Replier 用于发送 /help、用法提示等自动回复，client.Client 满足该接口
+type Replier interface {
+	Reply(ctx context.Context, taskID, sessionID, text string) error
+}
+
+type Handler func(ctx context.Context, req *Request) error
+
+// Request 是路由后的消息，命令消息会解析出命令名与参数
+type Request struct {
+	types.Message
+	Command string
+	Args    []string
+	RawArgs string
+}
+
+func (r *Request) Files() []*types.FilePart {
+	var out []*types.FilePart
+	for _, p := range r.Parts() {
+		if f, ok := p.(*types.FilePart); ok {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (r *Request) Data() []*types.DataPart {
+	var out []*types.DataPart
+	for _, p := range r.Parts() {
+		if d, ok := p.(*types.DataPart); ok {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+type command struct {
+	name        string
+	aliases     []string
+	description string
+	usage       string
+	minArgs     int
+	handler     Handler
+}
+
+type Option func(*command)
+
+func Alias(names ...string) Option {
+	return func(c *command) {
+		c.aliases = append(c.aliases, names...)
+	}
+}
+
+func Description(desc string) Option {
+	return func(c *command) {
+		c.description = desc
+	}
+}
+
+// Usage 设置参数说明，例如 "<城市> [天数]"
+func Usage(usage string) Option {
+	return func(c *command) {
+		c.usage = usage
+	}
+}
+
+// MinArgs 设置最少参数个数，不足时回复用法提示
+func MinArgs(n int) Option {
+	return func(c *command) {
+		c.minArgs = n
+	}
+}
+
+// Router 按斜杠命令、文件、结构化数据依次匹配消息，都不匹配时交给 fallback
+type Router struct {
+	replier  Replier
+	commands map[string]*command
+	list     []*command
+
+	onFile   Handler
+	onData   Handler
+	fallback Handler
+}
+
+func New(replier Replier) *Router {
+	r := &Router{
+		replier:  replier,
+		commands: make(map[string]*command),
+	}
+	r.Command("/help", r.help, Alias("/?"), Description("显示可用命令"))
+	return r
+}
+
+// Command 注册命令，重复注册同名命令会覆盖之前的处理器及其别名；
+// 命令名或别名已被其他命令占用时不做任何修改，返回 ErrCommandConflict
+func (r *Router) Command(name string, h Handler, opts ...Option) error {
+	cmd := &command{name: normalize(name), handler: h}
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	for i, alias := range cmd.aliases {
+		cmd.aliases[i] = normalize(alias)
+	}
+
+	for _, n := range append([]string{cmd.name}, cmd.aliases...) {
+		if other, ok := r.commands[n]; ok && other.name != cmd.name {
+			return &types.XiaoYiError{
+				Code:    ErrCommandConflict.Code,
+				Message: fmt.Sprintf("%s is already registered by %s", n, other.name),
+			}
+		}
+	}
+
+	if old, ok := r.commands[cmd.name]; ok {
+		r.remove(old)
+	}
+	r.list = append(r.list, cmd)
+	r.commands[cmd.name] = cmd
+	for _, alias := range cmd.aliases {
+		r.commands[alias] = cmd
+	}
+	return nil
+}
+
+func (r *Router) remove(cmd *command) {
+	for i, c := range r.list {
+		if c == cmd {
+			r.list = append(r.list[:i], r.list[i+1:]...)
+			break
+		}
+	}
+	for _, n := range append([]string{cmd.name}, cmd.aliases...) {
+		if r.commands[n] == cmd {
+			delete(r.commands, n)
+		}
+	}
+}
+
+// OnFile 处理包含 FilePart 的非命令消息
+func (r *Router) OnFile(h Handler) {
+	r.onFile = h
+}
+
+// OnData 处理包含 DataPart 的非命令消息
+func (r *Router) OnData(h Handler) {
+	r.onData = h
+}
+
+// Fallback 处理未匹配任何规则的消息，包括未注册的命令
+func (r *Router) Fallback(h Handler) {
+	r.fallback = h
+}
+
+// Handle 满足 client.MessageHandler，可直接传给 client.OnMessage
+func (r *Router) Handle(ctx context.Context, msg types.Message) error {
+	req := &Request{Message: msg}
+	text := strings.TrimSpace(msg.Text())
+
+	if strings.HasPrefix(text, "/") {
+		name, rawArgs := text, ""
+		if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+			name, rawArgs = text[:i], text[i:]
+		}
+		req.Command = normalize(name)
+		req.RawArgs = strings.TrimSpace(rawArgs)
+		req.Args = SplitArgs(req.RawArgs)
+
+		if cmd, ok := r.commands[req.Command]; ok {
+			req.Command = cmd.name
+			if len(req.Args) < cmd.minArgs {
+				return r.reply(ctx, msg, fmt.Sprintf("用法: %s", cmd.signature()))
+			}
+			return cmd.handler(ctx, req)
+		}
+		if r.fallback == nil {
+			return r.reply(ctx, msg, fmt.Sprintf("未知命令 %s，发送 /help 查看可用命令", req.Command))
+		}
+		return r.fallback(ctx, req)
+	}
+
+	switch {
+	case r.onFile != nil && len(req.Files()) > 0:
+		return r.onFile(ctx, req)
+	case r.onData != nil && len(req.Data()) > 0:
+		return r.onData(ctx, req)
+	case r.fallback != nil:
+		return r.fallback(ctx, req)
+	}
+	return nil
+}
+
+// Help 生成命令列表
+func (r *Router) Help() string {
+	cmds := make([]*command, len(r.list))
+	copy(cmds, r.list)
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].name < cmds[j].name })
+
+	var b strings.Builder
+	b.WriteString("可用命令:")
+	for _, cmd := range cmds {
+		b.WriteString("\n")
+		b.WriteString(cmd.signature())
+		if len(cmd.aliases) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(cmd.aliases, ", "))
+		}
+		if cmd.description != "" {
+			b.WriteString(" - ")
+			b.WriteString(cmd.description)
+		}
+	}
+	return b.String()
+}
+
+func (r *Router) help(ctx context.Context, req *Request) error {
+	return r.reply(ctx, req, r.Help())
+}
+
+func (r *Router) reply(ctx context.Context, msg types.Message, text string) error {
+	if r.replier == nil {
+		return ErrNoReplier
+	}
+	return r.replier.Reply(ctx, msg.TaskID(), msg.SessionID(), text)
+}
+
+func (c *command) signature() string {
+	if c.usage == "" {
+		return c.name
+	}
+	return c.name + " " + c.usage
+}
+
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	return name
+}
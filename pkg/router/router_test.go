@@ -0,0 +1,121 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+type recordReplier struct {
+	replies []string
+}
+
+func (r *recordReplier) Reply(ctx context.Context, taskID, sessionID, text string) error {
+	r.replies = append(r.replies, text)
+	return nil
+}
+
+func textMessage(text string) types.Message {
+	req := &types.A2ARequest{}
+	req.Params.Message.Parts = []types.Part{types.NewTextPart(text)}
+	return req
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		fallback    bool
+		wantCommand string
+		wantArgs    []string
+		wantReply   string
+	}{
+		{name: "命令与参数", text: "/weather 北京 3", wantCommand: "/weather", wantArgs: []string{"北京", "3"}},
+		{name: "别名且不区分大小写", text: "/W \"New York\"", wantCommand: "/weather", wantArgs: []string{"New York"}},
+		{name: "参数不足回复用法", text: "/weather", wantReply: "用法: /weather <城市>"},
+		{name: "未知命令回复提示", text: "/nope", wantReply: "未知命令 /nope"},
+		{name: "未知命令交给 fallback", text: "/nope x", fallback: true, wantCommand: "/nope", wantArgs: []string{"x"}},
+		{name: "普通文本交给 fallback", text: "你好", fallback: true},
+		{name: "自动生成 help", text: "/?", wantReply: "/weather <城市> (/w) - 查询天气"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := &recordReplier{}
+			r := New(rep)
+			var got *Request
+			record := func(ctx context.Context, req *Request) error {
+				got = req
+				return nil
+			}
+			if err := r.Command("/weather", record, Alias("/w"), Usage("<城市>"), MinArgs(1), Description("查询天气")); err != nil {
+				t.Fatal(err)
+			}
+			if tt.fallback {
+				r.Fallback(record)
+			}
+
+			if err := r.Handle(context.Background(), textMessage(tt.text)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantReply != "" {
+				if got != nil || len(rep.replies) != 1 || !strings.Contains(rep.replies[0], tt.wantReply) {
+					t.Fatalf("replies = %q, handler called = %v, want reply containing %q", rep.replies, got != nil, tt.wantReply)
+				}
+				return
+			}
+			if got == nil || len(rep.replies) != 0 {
+				t.Fatalf("handler called = %v, replies = %q", got != nil, rep.replies)
+			}
+			if got.Command != tt.wantCommand || !reflect.DeepEqual(got.Args, tt.wantArgs) {
+				t.Fatalf("command = %q args = %q, want %q %q", got.Command, got.Args, tt.wantCommand, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCommandConflict(t *testing.T) {
+	noop := func(ctx context.Context, req *Request) error { return nil }
+	tests := []struct {
+		name    string
+		command string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "别名与已有命令重名", command: "/weekly", opts: []Option{Alias("/weather")}, wantErr: true},
+		{name: "命令与已有别名重名", command: "/w", wantErr: true},
+		{name: "别名与已有别名重名", command: "/wind", opts: []Option{Alias("/W")}, wantErr: true},
+		{name: "与内置 help 的别名重名", command: "/?", wantErr: true},
+		{name: "重复注册同名命令", command: "/weather", opts: []Option{Alias("/tq")}},
+		{name: "不冲突", command: "/wind", opts: []Option{Alias("/wd")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(&recordReplier{})
+			if err := r.Command("/weather", noop, Alias("/w")); err != nil {
+				t.Fatal(err)
+			}
+			err := r.Command(tt.command, noop, tt.opts...)
+			if got := errors.Is(err, ErrCommandConflict); got != tt.wantErr {
+				t.Fatalf("Command(%s) err = %v, want conflict %v", tt.command, err, tt.wantErr)
+			}
+			if tt.wantErr && r.commands["/w"].name != "/weather" {
+				t.Fatal("failed registration changed existing aliases")
+			}
+		})
+	}
+
+	// 重复注册替换别名，旧别名不再指向该命令
+	r := New(&recordReplier{})
+	r.Command("/weather", noop, Alias("/w"))
+	r.Command("/weather", noop, Alias("/tq"))
+	if _, ok := r.commands["/w"]; ok {
+		t.Fatal("old alias still registered after re-registration")
+	}
+	if len(r.list) != 2 {
+		t.Fatalf("router lists %d commands, want /help and /weather", len(r.list))
+	}
+}
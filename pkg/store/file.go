@@ -0,0 +1,189 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+const fileSuffix = ".jsonl"
+
+// FileTaskStore 把每个任务的记录保存为 dir 下的一个 JSON Lines 文件，进程重启后仍可查询。
+// 首行是任务信息，之后每帧更新追加一行，写入开销与记录长度无关
+type FileTaskStore struct {
+	dir       string
+	retention time.Duration
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewFileTaskStore(dir string, retention time.Duration) (*FileTaskStore, error) {
+	if retention <= 0 {
+		retention = types.DefaultTaskRetention
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create task store dir: %w", err)
+	}
+	return &FileTaskStore{dir: dir, retention: retention}, nil
+}
+
+func (s *FileTaskStore) Append(taskID, sessionID string, update types.TaskUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(update.At)
+
+	line, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path(taskID), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	var buf []byte
+	if info.Size() == 0 {
+		header, err := json.Marshal(recordHeader{ID: taskID, SessionID: sessionID})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		buf = append(header, '\n')
+	}
+	buf = append(append(buf, line...), '\n')
+	// 整帧一次写入，中断时最多留下不完整的最后一行，读取时忽略
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileTaskStore) Get(taskID string) (*types.TaskRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.load(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if expired(rec, s.retention, time.Now()) {
+		return nil, types.ErrTaskNotFound
+	}
+	return rec, nil
+}
+
+func (s *FileTaskStore) Delete(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(taskID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path 对 taskID 做 URL 安全的 base64 编码，防止 ID 中的路径分隔符逃逸出 dir
+func (s *FileTaskStore) path(taskID string) string {
+	return filepath.Join(s.dir, base64.RawURLEncoding.EncodeToString([]byte(taskID))+fileSuffix)
+}
+
+func (s *FileTaskStore) load(taskID string) (*types.TaskRecord, error) {
+	data, err := os.ReadFile(s.path(taskID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, types.ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecord(data)
+}
+
+func (s *FileTaskStore) pruneLocked(now time.Time) {
+	if now.Sub(s.lastPrune) < s.retention/10 {
+		return
+	}
+	s.lastPrune = now
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) > s.retention {
+			os.Remove(filepath.Join(s.dir, e.Name()))
+		}
+	}
+}
+
+type recordHeader struct {
+	ID        string `json:"id"`
+	SessionID string `json:"sessionId"`
+}
+
+// decodeRecord 逐行还原记录，Part 是接口类型，需借助 protocol 的解码函数
+func decodeRecord(data []byte) (*types.TaskRecord, error) {
+	// 没有换行结尾的最后一行是写入中断留下的半帧
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		data = data[:i+1]
+	} else {
+		data = nil
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	if !sc.Scan() {
+		return nil, types.ErrTaskNotFound
+	}
+	var header recordHeader
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("decode task record: %w", err)
+	}
+
+	rec := &types.TaskRecord{ID: header.ID, SessionID: header.SessionID, State: types.TaskSubmitted}
+	for sc.Scan() {
+		var raw struct {
+			Artifact json.RawMessage     `json:"artifact"`
+			Status   json.RawMessage     `json:"status"`
+			Error    *types.JsonRpcError `json:"error"`
+			At       time.Time           `json:"at"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &raw); err != nil {
+			return nil, fmt.Errorf("decode task record: %w", err)
+		}
+		update := types.TaskUpdate{Error: raw.Error, At: raw.At}
+		var err error
+		if len(raw.Artifact) > 0 {
+			update.Artifact, err = protocol.DecodeArtifactUpdate(raw.Artifact)
+		} else if len(raw.Status) > 0 {
+			update.Status, err = protocol.DecodeStatusUpdate(raw.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode task record: %w", err)
+		}
+		rec.Apply(update)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("decode task record: %w", err)
+	}
+	return rec, nil
+}
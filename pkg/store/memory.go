@@ -0,0 +1,111 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// MemoryTaskStore 在内存中保存任务记录，超过 retention 未更新的记录会被清理，
+// 每个任务最多保留 maxUpdates 帧
+type MemoryTaskStore struct {
+	retention  time.Duration
+	maxUpdates int
+
+	mu        sync.Mutex
+	records   map[string]*types.TaskRecord
+	lastPrune time.Time
+}
+
+func NewMemoryTaskStore(retention time.Duration, maxUpdates int) *MemoryTaskStore {
+	if retention <= 0 {
+		retention = types.DefaultTaskRetention
+	}
+	if maxUpdates <= 0 {
+		maxUpdates = types.DefaultMaxTaskUpdates
+	}
+	return &MemoryTaskStore{
+		retention:  retention,
+		maxUpdates: maxUpdates,
+		records:    make(map[string]*types.TaskRecord),
+	}
+}
+
+func (s *MemoryTaskStore) Append(taskID, sessionID string, update types.TaskUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(update.At)
+
+	rec, ok := s.records[taskID]
+	if !ok {
+		rec = &types.TaskRecord{ID: taskID, SessionID: sessionID, State: types.TaskSubmitted}
+		s.records[taskID] = rec
+	}
+	rec.Apply(update)
+	if len(rec.Updates) > s.maxUpdates {
+		rec.Updates = trimUpdates(rec.Updates, s.maxUpdates)
+	}
+	return nil
+}
+
+func (s *MemoryTaskStore) Get(taskID string) (*types.TaskRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[taskID]
+	if !ok || expired(rec, s.retention, time.Now()) {
+		return nil, types.ErrTaskNotFound
+	}
+	out := *rec
+	out.Updates = append([]types.TaskUpdate(nil), rec.Updates...)
+	return &out, nil
+}
+
+func (s *MemoryTaskStore) Delete(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, taskID)
+	return nil
+}
+
+// pruneLocked 最多每隔 retention/10 扫描一次，避免每次写入都遍历全部记录
+func (s *MemoryTaskStore) pruneLocked(now time.Time) {
+	if now.Sub(s.lastPrune) < s.retention/10 {
+		return
+	}
+	s.lastPrune = now
+	for id, rec := range s.records {
+		if expired(rec, s.retention, now) {
+			delete(s.records, id)
+		}
+	}
+}
+
+// trimUpdates 把同一 artifact 的连续追加分片合并为一帧，仍超过 max 时丢弃最早的帧。
+// 合并后的帧重放结果与原分片相同；Get 返回的记录与这里共享帧，因此合并时总是新建帧
+func trimUpdates(updates []types.TaskUpdate, max int) []types.TaskUpdate {
+	out := make([]types.TaskUpdate, 0, len(updates))
+	for _, u := range updates {
+		if n := len(out); n > 0 && u.Artifact != nil && u.Artifact.Append && u.Error == nil {
+			prev := out[n-1].Artifact
+			if prev != nil && prev.Artifact.ArtifactID == u.Artifact.Artifact.ArtifactID {
+				merged := *prev
+				merged.Artifact.Parts = types.MergeParts(append([]types.Part(nil), prev.Artifact.Parts...), u.Artifact.Artifact.Parts)
+				merged.LastChunk = u.Artifact.LastChunk
+				merged.Final = u.Artifact.Final
+				out[n-1].Artifact = &merged
+				out[n-1].At = u.At
+				continue
+			}
+		}
+		out = append(out, u)
+	}
+	if len(out) > max {
+		out = append([]types.TaskUpdate(nil), out[len(out)-max:]...)
+	}
+	return out
+}
+
+func expired(rec *types.TaskRecord, retention time.Duration, now time.Time) bool {
+	return now.Sub(rec.UpdatedAt) > retention
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+type sessionEntry struct {
+	SessionID string         `json:"sessionId"`
+	ServerID  types.ServerID `json:"serverId"`
+	At        time.Time      `json:"at"`
+}
+
+// MemorySessionStore 以 LRU 方式保存会话绑定，超过 ttl 未活跃或超出 maxEntries 的会话被淘汰
+type MemorySessionStore struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+func NewMemorySessionStore(ttl time.Duration, maxEntries int) *MemorySessionStore {
+	if ttl <= 0 {
+		ttl = types.DefaultSessionTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = types.DefaultMaxSessions
+	}
+	return &MemorySessionStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (s *MemorySessionStore) Get(sessionID string) (types.ServerID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[sessionID]
+	if !ok {
+		return "", types.ErrSessionNotFound
+	}
+	entry := e.Value.(*sessionEntry)
+	if time.Since(entry.At) > s.ttl {
+		s.removeLocked(e)
+		return "", types.ErrSessionNotFound
+	}
+	return entry.ServerID, nil
+}
+
+func (s *MemorySessionStore) Set(sessionID string, serverID types.ServerID) error {
+	s.set(sessionID, serverID)
+	return nil
+}
+
+// set 保存绑定并刷新活跃时间，返回绑定的服务器是否发生变化
+func (s *MemorySessionStore) set(sessionID string, serverID types.ServerID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := true
+	if e, ok := s.entries[sessionID]; ok {
+		changed = e.Value.(*sessionEntry).ServerID != serverID
+	}
+	s.setLocked(sessionEntry{SessionID: sessionID, ServerID: serverID, At: time.Now()})
+	return changed
+}
+
+func (s *MemorySessionStore) Delete(sessionID string) error {
+	s.remove(sessionID)
+	return nil
+}
+
+func (s *MemorySessionStore) remove(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[sessionID]
+	if ok {
+		s.removeLocked(e)
+	}
+	return ok
+}
+
+func (s *MemorySessionStore) Touch(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[sessionID]; ok {
+		e.Value.(*sessionEntry).At = time.Now()
+		s.lru.MoveToFront(e)
+	}
+	return nil
+}
+
+func (s *MemorySessionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked()
+	return s.lru.Len()
+}
+
+func (s *MemorySessionStore) setLocked(entry sessionEntry) {
+	if e, ok := s.entries[entry.SessionID]; ok {
+		*e.Value.(*sessionEntry) = entry
+		s.lru.MoveToFront(e)
+	} else {
+		s.entries[entry.SessionID] = s.lru.PushFront(&entry)
+	}
+	s.evictLocked()
+}
+
+// evictLocked 从最久未活跃的一端淘汰过期或超出容量的会话
+func (s *MemorySessionStore) evictLocked() {
+	for e := s.lru.Back(); e != nil; e = s.lru.Back() {
+		if s.lru.Len() <= s.maxEntries && time.Since(e.Value.(*sessionEntry).At) <= s.ttl {
+			return
+		}
+		s.removeLocked(e)
+	}
+}
+
+func (s *MemorySessionStore) removeLocked(e *list.Element) {
+	s.lru.Remove(e)
+	delete(s.entries, e.Value.(*sessionEntry).SessionID)
+}
+
+// snapshot 按从旧到新的顺序返回全部会话
+func (s *MemorySessionStore) snapshot() []sessionEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked()
+	out := make([]sessionEntry, 0, s.lru.Len())
+	for e := s.lru.Back(); e != nil; e = e.Prev() {
+		out = append(out, *e.Value.(*sessionEntry))
+	}
+	return out
+}
+
+func (s *MemorySessionStore) restore(entries []sessionEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		s.setLocked(entry)
+	}
+}
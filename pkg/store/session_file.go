@@ -0,0 +1,160 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+const (
+	// saveDelay 合并短时间内的多次绑定变化，只写一次文件
+	saveDelay = time.Second
+	// touchSaveInterval 限制 Touch 触发的落盘频率，活跃时间的精度不需要高于 TTL
+	touchSaveInterval = time.Minute
+)
+
+// FileSessionStore 在内存 LRU 之上把会话绑定保存到单个 JSON 文件，进程重启后回复仍能路由到原服务器。
+// 绑定不变时不写文件，变化在后台合并写入，退出前调用 Close 保存最后的修改
+type FileSessionStore struct {
+	*MemorySessionStore
+	path string
+
+	mu     sync.Mutex
+	dirty  bool
+	timer  *time.Timer
+	due    time.Time
+	closed bool
+
+	saveMu sync.Mutex // 串行化文件写入
+}
+
+func NewFileSessionStore(path string, ttl time.Duration, maxEntries int) (*FileSessionStore, error) {
+	s := &FileSessionStore{
+		MemorySessionStore: NewMemorySessionStore(ttl, maxEntries),
+		path:               path,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create session store dir: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []sessionEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("decode session store: %w", err)
+	}
+	s.restore(entries)
+	return s, nil
+}
+
+func (s *FileSessionStore) Set(sessionID string, serverID types.ServerID) error {
+	if s.MemorySessionStore.set(sessionID, serverID) {
+		s.schedule(saveDelay)
+	} else {
+		s.schedule(touchSaveInterval)
+	}
+	return nil
+}
+
+func (s *FileSessionStore) Delete(sessionID string) error {
+	if s.MemorySessionStore.remove(sessionID) {
+		s.schedule(saveDelay)
+	}
+	return nil
+}
+
+func (s *FileSessionStore) Touch(sessionID string) error {
+	s.MemorySessionStore.Touch(sessionID)
+	s.schedule(touchSaveInterval)
+	return nil
+}
+
+// Flush 立即把未保存的修改写入文件
+func (s *FileSessionStore) Flush() error {
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+	return s.save()
+}
+
+// Close 保存未写入的修改，之后的变化只保留在内存中
+func (s *FileSessionStore) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.Flush()
+}
+
+// schedule 安排在 delay 后写文件，已有更早的写入计划时沿用
+func (s *FileSessionStore) schedule(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	if s.closed {
+		return
+	}
+	due := time.Now().Add(delay)
+	if s.timer != nil {
+		if !due.Before(s.due) {
+			return
+		}
+		s.timer.Stop()
+	}
+	s.due = due
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		if s.timer != t {
+			s.mu.Unlock()
+			return
+		}
+		s.timer = nil
+		s.mu.Unlock()
+		if err := s.save(); err != nil {
+			slog.Warn("会话绑定写入文件失败", "path", s.path, "error", err)
+		}
+	})
+	s.timer = t
+}
+
+// save 在有未保存的修改时写文件，先写临时文件再重命名，避免进程中断时留下半个文件
+func (s *FileSessionStore) save() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	dirty := s.dirty
+	s.dirty = false
+	s.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	data, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		// 保留修改标记，下一次保存时重试
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSessionStoreWritesOffCallPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	s, err := NewFileSessionStore(path, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("s1", "server1"); err != nil {
+		t.Fatal(err)
+	}
+	// Set 只修改内存，文件在后台合并写入
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Set 不应同步写文件: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewFileSessionStore(path, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reloaded.Get("s1"); err != nil || got != "server1" {
+		t.Fatalf("重新加载后 s1 = %q, %v", got, err)
+	}
+}
+
+func TestFileSessionStoreUnchangedBinding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	s, err := NewFileSessionStore(path, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Set("s1", "server1")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 绑定不变时只按 Touch 的频率落盘
+	s.Set("s1", "server1")
+	s.mu.Lock()
+	wait := time.Until(s.due)
+	s.mu.Unlock()
+	if wait < touchSaveInterval-time.Second {
+		t.Fatalf("绑定未变化却在 %v 后写文件", wait)
+	}
+
+	// 绑定变化时提前到 saveDelay
+	s.Set("s1", "server2")
+	s.mu.Lock()
+	wait = time.Until(s.due)
+	s.mu.Unlock()
+	if wait > saveDelay {
+		t.Fatalf("绑定变化后 %v 才写文件", wait)
+	}
+}
+
+func TestFileSessionStoreDebouncedSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	s, err := NewFileSessionStore(path, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 0; i < 100; i++ {
+		s.Set("s1", "server1")
+		s.Set("s1", "server2")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("修改没有在后台写入文件")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	reloaded, err := NewFileSessionStore(path, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloaded.Get("s1"); got != "server2" {
+		t.Fatalf("s1 = %q, want server2", got)
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+func chunk(text string, appendChunk, final bool) types.TaskUpdate {
+	return types.TaskUpdate{
+		Artifact: &types.ArtifactUpdate{
+			TaskID: "t1",
+			Kind:   "artifact-update",
+			Append: appendChunk,
+			Final:  final,
+			Artifact: types.ArtifactPayload{
+				ArtifactID: "a1",
+				Parts:      []types.Part{types.NewTextPart(text)},
+			},
+		},
+		At: time.Now(),
+	}
+}
+
+func artifactText(t *testing.T, rec *types.TaskRecord) string {
+	t.Helper()
+	arts := rec.Artifacts()
+	if len(arts) != 1 || len(arts[0].Parts) != 1 {
+		t.Fatalf("artifacts = %+v", arts)
+	}
+	return arts[0].Parts[0].(*types.TextPart).Text
+}
+
+func TestFileTaskStoreAppend(t *testing.T) {
+	s, err := NewFileTaskStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Append("t1", "s1", chunk("a", false, false))
+	s.Append("t1", "s1", chunk("b", true, false))
+	s.Append("t1", "s1", chunk("c", true, true))
+
+	rec, err := s.Get("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.SessionID != "s1" || rec.State != types.TaskCompleted || len(rec.Updates) != 3 {
+		t.Fatalf("record = %+v", rec)
+	}
+	if got := artifactText(t, rec); got != "abc" {
+		t.Fatalf("artifact = %q", got)
+	}
+}
+
+func TestFileTaskStoreTruncatedLine(t *testing.T) {
+	s, err := NewFileTaskStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Append("t1", "s1", chunk("a", false, false))
+
+	// 模拟写入中断留下的半行
+	f, err := os.OpenFile(s.path("t1"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"artifact":{"taskId"`)
+	f.Close()
+
+	rec, err := s.Get("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Updates) != 1 {
+		t.Fatalf("updates = %d, want 1", len(rec.Updates))
+	}
+}
+
+func TestMemoryTaskStoreMaxUpdates(t *testing.T) {
+	s := NewMemoryTaskStore(time.Hour, 10)
+	s.Append("t1", "s1", chunk("x", false, false))
+	for i := 0; i < 99; i++ {
+		s.Append("t1", "s1", chunk("x", true, false))
+	}
+	rec, err := s.Get("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Updates) > 10 {
+		t.Fatalf("updates = %d, want <= 10", len(rec.Updates))
+	}
+	// 合并后内容不变
+	if got := artifactText(t, rec); len(got) != 100 {
+		t.Fatalf("artifact 长度 = %d, want 100", len(got))
+	}
+
+	// 无法合并的状态帧超出上限时丢弃最早的
+	for i := 0; i < 20; i++ {
+		s.Append("t1", "s1", types.TaskUpdate{Error: &types.JsonRpcError{Code: -1}, At: time.Now()})
+	}
+	rec, _ = s.Get("t1")
+	if len(rec.Updates) != 10 {
+		t.Fatalf("updates = %d, want 10", len(rec.Updates))
+	}
+}
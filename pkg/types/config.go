@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const (
 	DefaultWSUrl1         = "wss://hag.cloud.huawei.com/openclaw/v1/ws/link"
@@ -13,8 +16,24 @@ const (
 	ReconnectMaxDelay     = 60 * time.Second
 	MaxReconnectAttempts  = 50
 	ConnectionTimeout     = 30 * time.Second
+
+	DefaultCoalesceInterval = 200 * time.Millisecond
+	DefaultMaxInlineBytes   = 10 << 20
+	DefaultTaskRetention    = time.Hour
+	DefaultMaxTaskUpdates   = 1000
+	DefaultDedupTTL         = 5 * time.Minute
+	DefaultDedupMaxEntries  = 10000
+	DefaultSessionTTL       = 24 * time.Hour
+	DefaultMaxSessions      = 10000
+	DefaultFailoverAfter    = 3
+	DefaultFailbackAfter    = 5 * time.Minute
+	DefaultQueueMaxAge      = 30 * time.Second
+	DefaultQueueMaxBytes    = 1 << 20
 )
 
+// DefaultMaxQueuedHandlers 是等待 worker 处理的消息数上限的默认值
+const DefaultMaxQueuedHandlers = 1000
+
 type Config struct {
 	AK              string
 	SK              string
@@ -24,6 +43,80 @@ type Config struct {
 	EnableStreaming bool
 	ReconnectDelay  time.Duration
 	SingleServer    bool // 只连接 server1，避免同一 agentID 多连接
+
+	Endpoints []Endpoint     // 网关接入点列表，为空时由 WSUrl1/WSUrl2 生成
+	Standby   *StandbyConfig // 主备模式，只连接一个接入点；nil 表示所有接入点同时连接
+
+	ReconnectPolicy ReconnectPolicy // 重连退避策略，nil 时为 DefaultReconnectPolicy(ReconnectDelay)
+	Transport       Transport       // 连接的建立与收发，nil 时使用 gorilla/websocket
+	Proxy           *ProxyConfig    // 代理，nil 时读取 HTTPS_PROXY/HTTP_PROXY/NO_PROXY 环境变量
+
+	MaxConcurrentHandlers int  // 消息处理并发数，0 表示在读循环中同步处理
+	PerSessionSerial      bool // 同一会话的消息按顺序串行处理
+	MaxQueuedHandlers     int  // 等待 worker 处理的消息数上限，超出时以 JSON-RPC 错误拒绝，默认 1000
+
+	Coalesce *CoalesceConfig // 流式输出合并，nil 表示每次写入发送一帧
+
+	MaxInlineBytes int // 发送文件 part 内联 bytes 的上限，默认 10MB
+
+	Compression   *CompressionConfig // permessage-deflate 压缩，nil 表示不协商
+	ReadLimit     int64              // 单条入站消息的字节数上限，超出时断开连接，0 表示不限
+	MaxFrameBytes int                // 单个出站帧的字节数上限，超出的 artifact 回复拆成追加分片，无法拆分的帧返回 ErrFrameTooLarge，0 表示不限
+
+	TaskStore      TaskStore     // 任务更新记录，nil 时使用内存存储
+	TaskRetention  time.Duration // 任务记录保留时长，默认 1h
+	MaxTaskUpdates int           // 内存存储中每个任务最多保留的帧数，超出时合并 artifact 分片，默认 1000
+
+	Dedup *DedupConfig // 双服务器重复请求抑制，nil 表示关闭
+
+	SessionStore SessionStore  // 会话与服务器的绑定，nil 时使用内存 LRU
+	SessionTTL   time.Duration // 会话绑定的保留时长，默认 24h
+	MaxSessions  int           // 内存中最多保留的会话数，默认 10000
+
+	Routing RoutingPolicy // 会话绑定的服务器断开时的回复路由策略，默认 RouteStrict
+
+	OutboundQueue *QueueConfig // 重连期间暂存待发送的帧，nil 表示不排队，服务器不可用时直接返回错误
+}
+
+// QueueConfig 控制每个服务器的出站队列，超过 MaxAge 的帧被丢弃并通过 OnError 报告
+type QueueConfig struct {
+	MaxAge   time.Duration // 帧在队列中的最长保留时间，默认 30s
+	MaxBytes int           // 队列中帧的总字节数上限，超出时返回 ErrQueueFull，默认 1MB
+}
+
+// RoutingPolicy 决定回复在会话绑定的服务器不可用时如何投递
+type RoutingPolicy string
+
+const (
+	RouteStrict    RoutingPolicy = "strict"    // 只发往绑定的服务器，不可用时返回 ErrServerNotReady
+	RouteFailover  RoutingPolicy = "failover"  // 绑定的服务器不可用时改发其他已连接的服务器，并重新绑定会话
+	RouteBroadcast RoutingPolicy = "broadcast" // 发往所有已连接的服务器，任一成功即视为送达
+)
+
+// CoalesceConfig 控制 Stream 的分片合并：缓冲写入的文本，按时间间隔、字节阈值或句子边界刷新
+type CoalesceConfig struct {
+	Interval           time.Duration // 最长缓冲时间，默认 200ms
+	MaxBytes           int           // 缓冲达到该字节数立即刷新，0 表示不限
+	FlushOnSentence    bool          // 遇到句末标点或换行时刷新
+	MaxFramesPerSecond int           // 每个任务每秒最多发送的帧数，0 表示不限
+}
+
+// CompressionConfig 控制 permessage-deflate 压缩，服务器不支持时不压缩
+type CompressionConfig struct {
+	Level int // flate 压缩级别 -2～9，0 时使用默认级别 1
+}
+
+// StandbyConfig 控制主备模式：当前接入点心跳超时或连续重连失败 FailoverAfter 次后切换到下一个接入点，
+// 在非 primary 接入点上稳定运行 FailbackAfter 后切回 primary
+type StandbyConfig struct {
+	FailoverAfter int           // 默认 3
+	FailbackAfter time.Duration // 默认 5m
+}
+
+// DedupConfig 控制重复请求抑制：相同 JSON-RPC id、taskId 与 messageId 的请求在 TTL 内只处理一次
+type DedupConfig struct {
+	TTL        time.Duration // 请求记录保留时长，默认 5m
+	MaxEntries int           // 最多记录的请求数，默认 10000
 }
 
 func DefaultConfig() *Config {
@@ -45,7 +138,21 @@ func (c *Config) Validate() error {
 	if c.AgentID == "" {
 		return &XiaoYiError{Code: "CONFIG_INVALID", Message: "AgentID is required"}
 	}
-	return nil
+	switch c.Routing {
+	case "", RouteStrict, RouteFailover, RouteBroadcast:
+	default:
+		return &XiaoYiError{Code: "CONFIG_INVALID", Message: "unknown routing policy: " + string(c.Routing)}
+	}
+	if c.Compression != nil && (c.Compression.Level < -2 || c.Compression.Level > 9) {
+		return &XiaoYiError{Code: "CONFIG_INVALID", Message: fmt.Sprintf("invalid compression level %d", c.Compression.Level)}
+	}
+	if c.ReadLimit < 0 || c.MaxFrameBytes < 0 {
+		return &XiaoYiError{Code: "CONFIG_INVALID", Message: "ReadLimit and MaxFrameBytes must not be negative"}
+	}
+	if err := c.Proxy.validate(); err != nil {
+		return &XiaoYiError{Code: "CONFIG_INVALID", Message: err.Error()}
+	}
+	return c.validateEndpoints()
 }
 
 func (c *Config) ApplyDefaults() {
@@ -58,4 +165,55 @@ func (c *Config) ApplyDefaults() {
 	if c.ReconnectDelay == 0 {
 		c.ReconnectDelay = DefaultReconnectDelay
 	}
+	if c.ReconnectPolicy == nil {
+		c.ReconnectPolicy = DefaultReconnectPolicy(c.ReconnectDelay)
+	}
+	if c.MaxQueuedHandlers <= 0 {
+		c.MaxQueuedHandlers = DefaultMaxQueuedHandlers
+	}
+	if c.TaskRetention <= 0 {
+		c.TaskRetention = DefaultTaskRetention
+	}
+	if c.MaxTaskUpdates <= 0 {
+		c.MaxTaskUpdates = DefaultMaxTaskUpdates
+	}
+	if c.MaxInlineBytes == 0 {
+		c.MaxInlineBytes = DefaultMaxInlineBytes
+	}
+	if c.SessionTTL <= 0 {
+		c.SessionTTL = DefaultSessionTTL
+	}
+	if c.MaxSessions <= 0 {
+		c.MaxSessions = DefaultMaxSessions
+	}
+	if c.Routing == "" {
+		c.Routing = RouteStrict
+	}
+	if c.Coalesce != nil && c.Coalesce.Interval <= 0 {
+		c.Coalesce.Interval = DefaultCoalesceInterval
+	}
+	if c.Standby != nil {
+		if c.Standby.FailoverAfter <= 0 {
+			c.Standby.FailoverAfter = DefaultFailoverAfter
+		}
+		if c.Standby.FailbackAfter <= 0 {
+			c.Standby.FailbackAfter = DefaultFailbackAfter
+		}
+	}
+	if c.OutboundQueue != nil {
+		if c.OutboundQueue.MaxAge <= 0 {
+			c.OutboundQueue.MaxAge = DefaultQueueMaxAge
+		}
+		if c.OutboundQueue.MaxBytes <= 0 {
+			c.OutboundQueue.MaxBytes = DefaultQueueMaxBytes
+		}
+	}
+	if c.Dedup != nil {
+		if c.Dedup.TTL <= 0 {
+			c.Dedup.TTL = DefaultDedupTTL
+		}
+		if c.Dedup.MaxEntries <= 0 {
+			c.Dedup.MaxEntries = DefaultDedupMaxEntries
+		}
+	}
 }
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+type EndpointRole string
+
+const (
+	RolePrimary EndpointRole = "primary"
+	RoleBackup  EndpointRole = "backup"
+)
+
+// Endpoint 描述一个网关接入点
+type Endpoint struct {
+	ID   ServerID // 可选，默认按顺序为 server1、server2…
+	URL  string
+	Role EndpointRole // 默认 RolePrimary；故障切换时优先使用 primary
+	TLS  *TLSConfig   // 可选，nil 时使用系统根证书校验
+}
+
+// ResolveEndpoints 返回实际使用的接入点列表：未配置 Endpoints 时由 WSUrl1/WSUrl2 生成，
+// SingleServer 时只保留第一个；primary 排在 backup 之前
+func (c *Config) ResolveEndpoints() []Endpoint {
+	var eps []Endpoint
+	if len(c.Endpoints) > 0 {
+		eps = append(eps, c.Endpoints...)
+	} else {
+		eps = []Endpoint{
+			{ID: Server1, URL: c.WSUrl1, Role: RolePrimary},
+			{ID: Server2, URL: c.WSUrl2, Role: RoleBackup},
+		}
+	}
+	if c.SingleServer {
+		eps = eps[:1]
+	}
+
+	for i := range eps {
+		if eps[i].ID == "" {
+			eps[i].ID = ServerID(fmt.Sprintf("server%d", i+1))
+		}
+		if eps[i].Role == "" {
+			eps[i].Role = RolePrimary
+		}
+	}
+	primaries := make([]Endpoint, 0, len(eps))
+	var backups []Endpoint
+	for _, ep := range eps {
+		if ep.Role == RoleBackup {
+			backups = append(backups, ep)
+		} else {
+			primaries = append(primaries, ep)
+		}
+	}
+	return append(primaries, backups...)
+}
+
+func (c *Config) validateEndpoints() error {
+	seen := make(map[ServerID]bool)
+	for _, ep := range c.ResolveEndpoints() {
+		if ep.URL == "" {
+			return &XiaoYiError{Code: "CONFIG_INVALID", Message: fmt.Sprintf("endpoint %s: URL is required", ep.ID)}
+		}
+		if ep.Role != RolePrimary && ep.Role != RoleBackup {
+			return &XiaoYiError{Code: "CONFIG_INVALID", Message: fmt.Sprintf("endpoint %s: unknown role %q", ep.ID, ep.Role)}
+		}
+		if err := ep.TLS.validate(); err != nil {
+			return &XiaoYiError{Code: "CONFIG_INVALID", Message: fmt.Sprintf("endpoint %s: %v", ep.ID, err)}
+		}
+		if host := ipHost(ep.URL); host != "" && (ep.TLS == nil || ep.TLS.ServerName == "" && len(ep.TLS.Pins) == 0) {
+			return &XiaoYiError{Code: "CONFIG_INVALID", Message: fmt.Sprintf("endpoint %s: wss endpoint with IP host %s requires TLS.ServerName or TLS.Pins", ep.ID, host)}
+		}
+		if seen[ep.ID] {
+			return &XiaoYiError{Code: "CONFIG_INVALID", Message: fmt.Sprintf("duplicate endpoint ID %s", ep.ID)}
+		}
+		seen[ep.ID] = true
+	}
+	return nil
+}
+
+// ipHost 返回 wss URL 中的 IP 主机；以 IP 访问时证书通常不含该 IP，必须显式指定校验用的主机名或指纹
+func ipHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "wss" || net.ParseIP(u.Hostname()) == nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+type FailoverKind string
+
+const (
+	Failover FailoverKind = "failover" // 从当前接入点切换到下一个接入点
+	Failback FailoverKind = "failback" // 从备用接入点切回 primary
+)
+
+// FailoverEvent 描述主备模式下的一次接入点切换
+type FailoverEvent struct {
+	Kind  FailoverKind
+	From  ServerID
+	To    ServerID
+	Cause error // 触发切换的原因，failback 时为 nil
+}
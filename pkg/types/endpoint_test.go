@@ -0,0 +1,43 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIPEndpointRequiresServerNameOrPins(t *testing.T) {
+	pin := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	tests := []struct {
+		name    string
+		ep      Endpoint
+		wantErr bool
+	}{
+		{name: "域名", ep: Endpoint{URL: DefaultWSUrl1}},
+		{name: "IP 未指定主机名", ep: Endpoint{URL: DefaultWSUrl2}, wantErr: true},
+		{name: "IP 指定主机名", ep: Endpoint{URL: DefaultWSUrl2, TLS: &TLSConfig{ServerName: "gateway.example.com"}}},
+		{name: "IP 指定指纹", ep: Endpoint{URL: DefaultWSUrl2, TLS: &TLSConfig{Pins: []string{pin}}}},
+		{name: "明文 ws 不校验证书", ep: Endpoint{URL: "ws://127.0.0.1:8080/ws"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{AK: "ak", SK: "sk", AgentID: "agent", Endpoints: []Endpoint{tt.ep}}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrConfigInvalid) {
+				t.Fatalf("Validate() = %v, want ErrConfigInvalid", err)
+			}
+		})
+	}
+
+	// 未配置 Endpoints 时默认备用服务器同样需要显式配置
+	cfg := &Config{AK: "ak", SK: "sk", AgentID: "agent", WSUrl1: DefaultWSUrl1, WSUrl2: DefaultWSUrl2}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("default WSUrl2 passed validation without ServerName or Pins")
+	}
+	cfg.SingleServer = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("single server config: %v", err)
+	}
+}
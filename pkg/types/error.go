@@ -19,6 +19,12 @@ func (e *XiaoYiError) Unwrap() error {
 	return e.Err
 }
 
+// Is 按错误码匹配，使 errors.Is(err, ErrXxx) 对携带详情的同类错误同样成立
+func (e *XiaoYiError) Is(target error) bool {
+	t, ok := target.(*XiaoYiError)
+	return ok && t.Code == e.Code
+}
+
 var (
 	ErrNotConnected    = &XiaoYiError{Code: "NOT_CONNECTED", Message: "not connected to server"}
 	ErrSessionNotFound = &XiaoYiError{Code: "SESSION_NOT_FOUND", Message: "session not found"}
@@ -26,4 +32,34 @@ var (
 	ErrServerNotReady  = &XiaoYiError{Code: "SERVER_NOT_READY", Message: "server not ready"}
 	ErrSendFailed      = &XiaoYiError{Code: "SEND_FAILED", Message: "failed to send message"}
 	ErrConnectFailed   = &XiaoYiError{Code: "CONNECT_FAILED", Message: "failed to connect"}
+	ErrCertPinMismatch = &XiaoYiError{Code: "CERT_PIN_MISMATCH", Message: "server certificate does not match any pin"}
+
+	ErrProxyFailed       = &XiaoYiError{Code: "PROXY_FAILED", Message: "proxy connection failed"}
+	ErrProxyAuthRequired = &XiaoYiError{Code: "PROXY_AUTH_REQUIRED", Message: "proxy requires authentication"}
+	ErrProxyAuthFailed   = &XiaoYiError{Code: "PROXY_AUTH_FAILED", Message: "proxy rejected credentials"}
+
+	ErrQueueFull     = &XiaoYiError{Code: "QUEUE_FULL", Message: "outbound queue is full"}
+	ErrFrameExpired  = &XiaoYiError{Code: "FRAME_EXPIRED", Message: "frame expired in outbound queue"}
+	ErrFrameTooLarge = &XiaoYiError{Code: "FRAME_TOO_LARGE", Message: "outbound frame exceeds MaxFrameBytes"}
+
+	ErrTaskCanceled   = &XiaoYiError{Code: "TASK_CANCELED", Message: "task canceled by server"}
+	ErrContextCleared = &XiaoYiError{Code: "CONTEXT_CLEARED", Message: "session context cleared"}
+	ErrClientClosed   = &XiaoYiError{Code: "CLIENT_CLOSED", Message: "client closed"}
+
+	ErrInvalidTransition = &XiaoYiError{Code: "INVALID_TRANSITION", Message: "invalid task state transition"}
+	ErrTaskFinished      = &XiaoYiError{Code: "TASK_FINISHED", Message: "task already finished"}
+	ErrStreamClosed      = &XiaoYiError{Code: "STREAM_CLOSED", Message: "stream already closed"}
+	ErrTaskNotFound      = &XiaoYiError{Code: "TASK_NOT_FOUND", Message: "task not found"}
+
+	ErrInvalidPart  = &XiaoYiError{Code: "INVALID_PART", Message: "invalid message part"}
+	ErrPartTooLarge = &XiaoYiError{Code: "PART_TOO_LARGE", Message: "inline file exceeds size limit"}
+
+	ErrHandlerPanic   = &XiaoYiError{Code: "HANDLER_PANIC", Message: "message handler panicked"}
+	ErrHandlerTimeout = &XiaoYiError{Code: "HANDLER_TIMEOUT", Message: "message handler timed out"}
+	ErrHandlerFailed  = &XiaoYiError{Code: "HANDLER_ERROR", Message: "message handler failed"}
+
+	ErrFileUnavailable  = &XiaoYiError{Code: "FILE_UNAVAILABLE", Message: "file content unavailable"}
+	ErrFileTooLarge     = &XiaoYiError{Code: "FILE_TOO_LARGE", Message: "file exceeds size limit"}
+	ErrFileTypeMismatch = &XiaoYiError{Code: "FILE_TYPE_MISMATCH", Message: "file content type mismatch"}
+	ErrFileNotText      = &XiaoYiError{Code: "FILE_NOT_TEXT", Message: "file is not a text file"}
 )
@@ -1,10 +1,29 @@
 package types
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type Message interface {
 	TaskID() string
 	SessionID() string
 	Text() string
 	Parts() []Part
+
+	// 以下访问方法统一带 Get 前缀，与 A2ARequest 上的同名字段区分
+	GetRequestID() string
+	GetMethod() string
+	GetDeviceID() string
+	GetConversationID() string
+	GetAgentLoginSessionID() string
+	GetMessageID() string
+	GetRole() string
+	GetServerID() ServerID
+	GetReceivedAt() time.Time
+	// GetMetadata 返回协议中未识别的字段，params 内的字段以 "params." 为前缀
+	GetMetadata() map[string]json.RawMessage
 }
 
 type Part interface {
@@ -63,6 +82,67 @@ func NewDataPart(data any) *DataPart {
 
 func (p *DataPart) Kind() string { return p.KindField }
 
+// MergeParts 把 parts 追加到 dst，相邻文本 part 合并为一个，空文本 part 被忽略
+func MergeParts(dst, parts []Part) []Part {
+	for _, p := range parts {
+		tp, ok := p.(*TextPart)
+		if !ok {
+			dst = append(dst, p)
+			continue
+		}
+		if tp.Text == "" {
+			continue
+		}
+		if len(dst) > 0 {
+			if last, ok := dst[len(dst)-1].(*TextPart); ok {
+				dst[len(dst)-1] = NewTextPart(last.Text + tp.Text)
+				continue
+			}
+		}
+		dst = append(dst, p)
+	}
+	return dst
+}
+
+// UnknownPart 保留未识别 kind 的原始 JSON，序列化时原样输出
+type UnknownPart struct {
+	KindField string
+	Raw       json.RawMessage
+}
+
+func (p *UnknownPart) Kind() string { return p.KindField }
+
+// MarshalJSON 在没有原始 JSON 时只输出 kind，kind 也为空时输出 null
+func (p *UnknownPart) MarshalJSON() ([]byte, error) {
+	if len(p.Raw) > 0 {
+		return p.Raw, nil
+	}
+	if p.KindField != "" {
+		return json.Marshal(map[string]string{"kind": p.KindField})
+	}
+	return []byte("null"), nil
+}
+
+// PartDecoder 把某个 kind 的原始 JSON 解码为自定义 Part
+type PartDecoder func(raw json.RawMessage) (Part, error)
+
+// ParseWarning 描述解析消息 parts 时被丢弃或解码失败的 part
+type ParseWarning struct {
+	Index   int
+	Kind    string
+	Raw     json.RawMessage
+	Err     error
+	Dropped bool
+}
+
+func (w ParseWarning) String() string {
+	action := "kept as UnknownPart"
+	if w.Dropped {
+		action = "dropped"
+	}
+	return fmt.Sprintf("part %d (kind %q) %s: %v", w.Index, w.Kind, action, w.Err)
+}
+
 type A2ARequest struct {
 	JSONRPC        string        `json:"jsonrpc"`
 	ID             string        `json:"id"`
@@ -72,6 +152,10 @@ type A2ARequest struct {
 	ConversationID string        `json:"conversationId,omitempty"`
 	SessionIDField string        `json:"sessionId,omitempty"`
 	Params         RequestParams `json:"params"`
+
+	ServerID   ServerID                   `json:"-"`
+	ReceivedAt time.Time                  `json:"-"`
+	Metadata   map[string]json.RawMessage `json:"-"`
 }
 
 type RequestParams struct {
@@ -112,6 +196,20 @@ func (r *A2ARequest) Parts() []Part {
 	return r.Params.Message.Parts
 }
 
+func (r *A2ARequest) GetRequestID() string           { return r.ID }
+func (r *A2ARequest) GetMethod() string              { return r.Method }
+func (r *A2ARequest) GetDeviceID() string            { return r.DeviceID }
+func (r *A2ARequest) GetConversationID() string      { return r.ConversationID }
+func (r *A2ARequest) GetAgentLoginSessionID() string { return r.Params.AgentLoginSessionID }
+func (r *A2ARequest) GetMessageID() string           { return r.Params.Message.MessageID }
+func (r *A2ARequest) GetRole() string                { return r.Params.Message.Role }
+func (r *A2ARequest) GetServerID() ServerID          { return r.ServerID }
+func (r *A2ARequest) GetReceivedAt() time.Time       { return r.ReceivedAt }
+
+func (r *A2ARequest) GetMetadata() map[string]json.RawMessage {
+	return r.Metadata
+}
+
 type A2AResponse struct {
 	SessionID string           `json:"sessionId"`
 	MessageID string           `json:"messageId"`
@@ -1,5 +1,7 @@
 package types
 
+import "fmt"
+
 type ServerID string
 
 const (
@@ -12,6 +14,7 @@ type ServerState struct {
 	Ready          bool
 	LastHeartbeat  int64
 	ReconnectCount int
+	GaveUp         bool // 重连策略已放弃，需调用 Reconnect 恢复
 }
 
 type ConnectionState struct {
@@ -21,6 +24,24 @@ type ConnectionState struct {
 	ReconnectCount int
 	Server1Ready   bool
 	Server2Ready   bool
+	Endpoints      []EndpointState
+}
+
+// EndpointState 是单个接入点的连接状态
+type EndpointState struct {
+	ID   ServerID
+	URL  string
+	Role EndpointRole
+	ServerState
+}
+
+// Metrics 是 Manager 运行期间的累计计数
+type Metrics struct {
+	DuplicatesDropped uint64 // 被去重丢弃的重复请求数
+	Rerouted          uint64 // 未经会话绑定的服务器送达的回复数
+	FramesQueued      uint64 // 因服务器不可用进入重连队列的帧数
+	FramesExpired     uint64 // 在重连队列中过期被丢弃的帧数
+	ActiveSessions    int    // 当前未过期的会话绑定数
 }
 
 type OutboundMessage struct {
@@ -38,11 +59,25 @@ type JsonRpcResponse struct {
 	Error   *JsonRpcError `json:"error,omitempty"`
 }
 
+const (
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	RPCTaskNotFound   = -32001
+	RPCServerBusy     = -32000
+)
+
 type JsonRpcError struct {
 	Code    any    `json:"code"`
 	Message string `json:"message"`
 }
 
+// Error 使 JsonRpcError 可以作为方法处理器的错误返回，原样回复错误码
+func (e *JsonRpcError) Error() string {
+	return fmt.Sprintf("[%v] %s", e.Code, e.Message)
+}
+
 type ArtifactUpdate struct {
 	TaskID    string          `json:"taskId"`
 	Kind      string          `json:"kind"`
@@ -0,0 +1,113 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyConfig 是连接网关时使用的代理，对所有接入点生效
+type ProxyConfig struct {
+	URL      string   // http://、https://、socks5:// 或 socks5h://，为空时读取 HTTPS_PROXY/HTTP_PROXY 环境变量
+	Username string   // 可选，覆盖 URL 中的用户名
+	Password string   // 可选，覆盖 URL 中的密码
+	NoProxy  []string // 直连的主机：域名（含子域名）、".example.com"、IP、CIDR 或 "*"
+}
+
+// ProxyURL 返回连接 target 时使用的代理，nil 表示直连；p 为 nil 时按 HTTPS_PROXY、HTTP_PROXY 与 NO_PROXY 环境变量决定
+func (p *ProxyConfig) ProxyURL(target *url.URL) (*url.URL, error) {
+	if p == nil || p.URL == "" {
+		proxy, err := envProxy(target)
+		if err != nil || proxy == nil {
+			return proxy, err
+		}
+		if p != nil && p.bypass(target.Hostname()) {
+			return nil, nil
+		}
+		return p.withAuth(proxy), nil
+	}
+	if p.bypass(target.Hostname()) {
+		return nil, nil
+	}
+	proxy, err := parseProxyURL(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	return p.withAuth(proxy), nil
+}
+
+func (p *ProxyConfig) validate() error {
+	if p == nil || p.URL == "" {
+		return nil
+	}
+	_, err := parseProxyURL(p.URL)
+	return err
+}
+
+func (p *ProxyConfig) withAuth(proxy *url.URL) *url.URL {
+	if p == nil || (p.Username == "" && p.Password == "") {
+		return proxy
+	}
+	u := *proxy
+	u.User = url.UserPassword(p.Username, p.Password)
+	return &u
+}
+
+func (p *ProxyConfig) bypass(host string) bool {
+	if p == nil {
+		return false
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		default:
+			entry = strings.TrimPrefix(entry, ".")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// envProxy 把 ws/wss 映射为 http/https 后交给 http.ProxyFromEnvironment
+func envProxy(target *url.URL) (*url.URL, error) {
+	u := *target
+	switch u.Scheme {
+	case "wss":
+		u.Scheme = "https"
+	case "ws":
+		u.Scheme = "http"
+	}
+	return http.ProxyFromEnvironment(&http.Request{URL: &u})
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return u, nil
+}
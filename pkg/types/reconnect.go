@@ -0,0 +1,81 @@
+package types
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// ReconnectPolicy 决定第 attempt 次（从 1 开始）重连前的等待时间，prev 为上一次的等待时间；
+// ok 为 false 表示放弃重连
+type ReconnectPolicy interface {
+	Next(attempt int, prev time.Duration) (delay time.Duration, ok bool)
+}
+
+type Jitter int
+
+const (
+	JitterNone         Jitter = iota // 不加抖动
+	JitterFull                       // 在 [0, 退避时间) 内均匀随机
+	JitterDecorrelated               // 在 [Base, 3×上次等待时间) 内均匀随机
+)
+
+// ExponentialBackoff 以 Base 为起点按 2 的幂增长，不超过 Max；MaxAttempts 为 0 表示无限重试
+type ExponentialBackoff struct {
+	Base        time.Duration
+	Max         time.Duration
+	MaxAttempts int
+	Jitter      Jitter
+}
+
+func (b *ExponentialBackoff) Next(attempt int, prev time.Duration) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return 0, false
+	}
+	base := b.Base
+	if base <= 0 {
+		base = DefaultReconnectDelay
+	}
+	ceiling := b.Max
+	if ceiling <= 0 {
+		ceiling = ReconnectMaxDelay
+	}
+
+	switch b.Jitter {
+	case JitterDecorrelated:
+		upper := max(prev*3, base+1)
+		return min(base+rand.N(upper-base), ceiling), true
+	case JitterFull:
+		return rand.N(backoff(base, ceiling, attempt) + 1), true
+	}
+	return backoff(base, ceiling, attempt), true
+}
+
+func backoff(base, ceiling time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < ceiling; i++ {
+		delay *= 2
+	}
+	return min(delay, ceiling)
+}
+
+// ConstantBackoff 每次等待固定时间；MaxAttempts 为 0 表示无限重试
+type ConstantBackoff struct {
+	Delay       time.Duration
+	MaxAttempts int
+}
+
+func (b *ConstantBackoff) Next(attempt int, prev time.Duration) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return 0, false
+	}
+	return b.Delay, true
+}
+
+// DefaultReconnectPolicy 与早期版本行为一致：从 base 开始指数退避，最长 60s，最多 50 次
+func DefaultReconnectPolicy(base time.Duration) ReconnectPolicy {
+	return &ExponentialBackoff{
+		Base:        base,
+		Max:         ReconnectMaxDelay,
+		MaxAttempts: MaxReconnectAttempts,
+	}
+}
@@ -0,0 +1,111 @@
+package types
+
+import "time"
+
+// TaskUpdate 是发送给小艺的一帧任务更新，三个字段只会设置一个
+type TaskUpdate struct {
+	Artifact *ArtifactUpdate `json:"artifact,omitempty"`
+	Status   *StatusUpdate   `json:"status,omitempty"`
+	Error    *JsonRpcError   `json:"error,omitempty"`
+	At       time.Time       `json:"at"`
+}
+
+// State 返回该更新使任务进入的状态，artifact 中间分片返回空
+func (u *TaskUpdate) State() TaskState {
+	switch {
+	case u.Error != nil:
+		return TaskFailed
+	case u.Status != nil:
+		return TaskState(u.Status.Status.State)
+	case u.Artifact != nil && u.Artifact.Final:
+		return TaskCompleted
+	}
+	return ""
+}
+
+type TaskRecord struct {
+	ID        string       `json:"id"`
+	SessionID string       `json:"sessionId"`
+	State     TaskState    `json:"state"`
+	Updates   []TaskUpdate `json:"updates"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+// Apply 追加一帧更新并刷新状态
+func (r *TaskRecord) Apply(u TaskUpdate) {
+	r.Updates = append(r.Updates, u)
+	if state := u.State(); state != "" {
+		r.State = state
+	} else if r.State == "" || r.State == TaskSubmitted {
+		r.State = TaskWorking
+	}
+	r.UpdatedAt = u.At
+}
+
+// LastStatus 返回最近一次状态更新
+func (r *TaskRecord) LastStatus() *StatusUpdate {
+	for i := len(r.Updates) - 1; i >= 0; i-- {
+		if r.Updates[i].Status != nil {
+			return r.Updates[i].Status
+		}
+	}
+	return nil
+}
+
+// Artifacts 按 artifactId 合并分片，非 append 分片会替换同一 artifact 之前的内容
+func (r *TaskRecord) Artifacts() []ArtifactPayload {
+	var out []ArtifactPayload
+	index := make(map[string]int)
+	for _, u := range r.Updates {
+		if u.Artifact == nil {
+			continue
+		}
+		a := u.Artifact.Artifact
+		i, ok := index[a.ArtifactID]
+		switch {
+		case !ok:
+			i = len(out)
+			index[a.ArtifactID] = i
+			out = append(out, ArtifactPayload{ArtifactID: a.ArtifactID})
+		case !u.Artifact.Append:
+			out[i].Parts = nil
+		}
+		out[i].Parts = MergeParts(out[i].Parts, a.Parts)
+	}
+	return out
+}
+
+// TaskStore 记录通过 client 发送的任务更新，用于响应 tasks/get 与 tasks/resubscribe
+type TaskStore interface {
+	Append(taskID, sessionID string, update TaskUpdate) error
+	// Get 在任务不存在或已过期时返回 ErrTaskNotFound
+	Get(taskID string) (*TaskRecord, error)
+	Delete(taskID string) error
+}
+
+// TaskResult 是 tasks/get 的响应结果
+type TaskResult struct {
+	ID        string            `json:"id"`
+	ContextID string            `json:"contextId,omitempty"`
+	Kind      string            `json:"kind"`
+	Status    TaskResultStatus  `json:"status"`
+	Artifacts []ArtifactPayload `json:"artifacts,omitempty"`
+}
+
+type TaskResultStatus struct {
+	State     TaskState    `json:"state"`
+	Message   *MessageBody `json:"message,omitempty"`
+	Timestamp string       `json:"timestamp,omitempty"`
+}
+
+// SessionStore 记录会话与服务器的绑定关系，回复按绑定的服务器路由
+type SessionStore interface {
+	// Get 在会话不存在或已过期时返回 ErrSessionNotFound
+	Get(sessionID string) (ServerID, error)
+	Set(sessionID string, serverID ServerID) error
+	Delete(sessionID string) error
+	// Touch 刷新会话的活跃时间，不改变绑定
+	Touch(sessionID string) error
+	// Len 返回未过期的会话数
+	Len() int
+}
@@ -0,0 +1,53 @@
+package types
+
+import "fmt"
+
+type TaskState string
+
+const (
+	TaskSubmitted     TaskState = "submitted"
+	TaskWorking       TaskState = "working"
+	TaskInputRequired TaskState = "input-required"
+	TaskCompleted     TaskState = "completed"
+	TaskCanceled      TaskState = "canceled"
+	TaskFailed        TaskState = "failed"
+)
+
+func (s TaskState) Valid() bool {
+	switch s {
+	case TaskSubmitted, TaskWorking, TaskInputRequired, TaskCompleted, TaskCanceled, TaskFailed:
+		return true
+	}
+	return false
+}
+
+func (s TaskState) IsFinal() bool {
+	return s == TaskCompleted || s == TaskCanceled || s == TaskFailed
+}
+
+func (s TaskState) CanTransition(to TaskState) bool {
+	if s.IsFinal() || !to.Valid() {
+		return false
+	}
+	return to != TaskSubmitted
+}
+
+type TransitionError struct {
+	TaskID string
+	From   TaskState
+	To     TaskState
+}
+
+func (e *TransitionError) Error() string {
+	if e.From.IsFinal() {
+		return fmt.Sprintf("[%s] task %s already %s, cannot move to %s", ErrTaskFinished.Code, e.TaskID, e.From, e.To)
+	}
+	return fmt.Sprintf("[%s] task %s cannot move from %s to %s", ErrInvalidTransition.Code, e.TaskID, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	if target == ErrTaskFinished {
+		return e.From.IsFinal()
+	}
+	return target == ErrInvalidTransition
+}
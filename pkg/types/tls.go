@@ -0,0 +1,42 @@
+package types
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+)
+
+// TLSConfig 是单个接入点的 TLS 设置
+type TLSConfig struct {
+	RootCAs      *x509.CertPool    // 自定义根证书，nil 时使用系统根证书
+	ServerName   string            // 校验证书时使用的主机名，用于以 IP 访问的接入点
+	Pins         []string          // 证书公钥指纹（base64 编码的 SPKI SHA-256），证书链中任一证书匹配即通过
+	Certificates []tls.Certificate // 双向 TLS 的客户端证书
+	MinVersion   uint16            // 最低 TLS 版本，默认 tls.VersionTLS12
+
+	InsecureSkipVerify bool // 跳过证书链与主机名校验，仅用于调试；配置了 Pins 时仍校验指纹
+}
+
+// SPKIPin 计算证书公钥的指纹，结果可直接用于 TLSConfig.Pins
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (t *TLSConfig) validate() error {
+	if t == nil {
+		return nil
+	}
+	for _, pin := range t.Pins {
+		b, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid pin %q: want base64-encoded SHA-256", pin)
+		}
+	}
+	if t.MinVersion != 0 && t.MinVersion < tls.VersionTLS10 {
+		return fmt.Errorf("invalid TLS MinVersion 0x%04x", t.MinVersion)
+	}
+	return nil
+}
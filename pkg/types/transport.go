@@ -0,0 +1,33 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Conn 是一条已建立的连接；ReadMessage 只在一个 goroutine 中调用，WriteMessage 与 Ping 由调用方串行化
+type Conn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(data []byte) error
+	// Ping 发送协议层 ping，收到 pong 时调用 Dial 传入的 onPong
+	Ping() error
+	Close() error
+}
+
+// Transport 负责建立到服务器的连接，Manager 只通过该接口收发数据
+type Transport interface {
+	Dial(ctx context.Context, ep Endpoint, header http.Header, onPong func()) (Conn, error)
+}
+
+const CloseNormalClosure = 1000
+
+// CloseError 表示对端发送关闭帧结束了连接
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("connection closed: %d %s", e.Code, e.Text)
+}
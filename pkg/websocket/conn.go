@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// serverConn 保存单个服务器的连接与状态，所有字段都在 mu 保护下读写
+type serverConn struct {
+	id       types.ServerID
+	endpoint types.Endpoint
+	queue    *outboundQueue
+
+	mu          sync.Mutex
+	conn        types.Conn
+	state       types.ServerState
+	connectedAt time.Time
+
+	// writeMu 串行化写入，避免慢写阻塞状态查询
+	writeMu sync.Mutex
+
+	// reconnect 只缓存一个待处理的重连事件，已有事件时新的事件被合并，发送方不会阻塞
+	reconnect chan reconnectEvent
+	// lastDelay 是上一次重连的等待时间，仅由该接入点的 reconnectLoop 访问
+	lastDelay time.Duration
+}
+
+func newServerConn(ep types.Endpoint) *serverConn {
+	return &serverConn{id: ep.ID, endpoint: ep, reconnect: make(chan reconnectEvent, 1)}
+}
+
+// scheduleReconnect 安排一次重连，已有待处理的重连时直接返回
+func (s *serverConn) scheduleReconnect(event reconnectEvent) {
+	select {
+	case s.reconnect <- event:
+	default:
+	}
+}
+
+// attach 绑定新建立的连接并标记为就绪
+func (s *serverConn) attach(conn types.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn = conn
+	s.state.Connected = true
+	s.state.Ready = true
+	s.state.LastHeartbeat = time.Now().Unix()
+	s.connectedAt = time.Now()
+}
+
+// detach 在 conn 仍是当前连接时解除绑定并关闭它，返回是否由本次调用解除；
+// 读循环与 ping 循环可能同时发现断线，只有成功解除的一方负责触发重连
+func (s *serverConn) detach(conn types.Conn) bool {
+	s.mu.Lock()
+	if s.conn == nil || (conn != nil && s.conn != conn) {
+		s.mu.Unlock()
+		return false
+	}
+	current := s.conn
+	s.conn = nil
+	s.state.Connected = false
+	s.state.Ready = false
+	s.mu.Unlock()
+
+	current.Close()
+	return true
+}
+
+func (s *serverConn) current() types.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+func (s *serverConn) send(msg *types.OutboundMessage) error {
+	conn := s.current()
+	if conn == nil {
+		return types.ErrServerNotReady
+	}
+	data, err := protocol.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	slog.Debug("发送消息", "server", s.id, "data", string(data))
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return conn.WriteMessage(data)
+}
+
+func (s *serverConn) ping(conn types.Conn) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return conn.Ping()
+}
+
+func (s *serverConn) touchHeartbeat() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.LastHeartbeat = time.Now().Unix()
+}
+
+func (s *serverConn) heartbeatExpired() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(time.Unix(s.state.LastHeartbeat, 0)) > types.HeartbeatTimeout
+}
+
+func (s *serverConn) ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Ready && s.conn != nil
+}
+
+func (s *serverConn) snapshot() types.ServerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// nextAttempt 增加重连计数并返回本次是第几次重连
+func (s *serverConn) nextAttempt() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.ReconnectCount++
+	return s.state.ReconnectCount
+}
+
+// resetAttempts 清零重连计数，stableOnly 时仅在连接仍保持时清零
+func (s *serverConn) resetAttempts(stableOnly bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stableOnly && !s.state.Connected {
+		return false
+	}
+	s.state.ReconnectCount = 0
+	s.state.GaveUp = false
+	return true
+}
+
+func (s *serverConn) giveUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.GaveUp = true
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+type dedupEntry struct {
+	key string
+	at  time.Time
+}
+
+// dedupCache 记录最近处理过的请求，双服务器在故障切换时重复投递的同一请求只处理一次
+type dedupCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// order 按插入顺序保存 dedupEntry，淘汰只移除队首，开销与淘汰的记录数成正比
+	order *list.List
+}
+
+func newDedupCache(cfg *types.DedupConfig) *dedupCache {
+	return &dedupCache{
+		ttl:        cfg.TTL,
+		maxEntries: cfg.MaxEntries,
+		seen:       make(map[string]time.Time),
+		order:      list.New(),
+	}
+}
+
+// dedupKey 由 JSON-RPC id、taskId 与 messageId 组成，id 为空的请求不参与去重
+func dedupKey(msg *types.A2ARequest) string {
+	if msg.ID == "" {
+		return ""
+	}
+	return msg.Method + "\x00" + msg.ID + "\x00" + msg.TaskID() + "\x00" + msg.GetMessageID()
+}
+
+// seenBefore 在 key 仍处于 TTL 内时返回 true，否则记录 key 并返回 false
+func (d *dedupCache) seenBefore(key string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if at, ok := d.seen[key]; ok && now.Sub(at) <= d.ttl {
+		return true
+	}
+	d.seen[key] = now
+	d.order.PushBack(dedupEntry{key: key, at: now})
+	d.evictLocked(now)
+	return false
+}
+
+// evictLocked 按插入顺序淘汰过期或超出容量的记录
+func (d *dedupCache) evictLocked(now time.Time) {
+	for front := d.order.Front(); front != nil; front = d.order.Front() {
+		e := front.Value.(dedupEntry)
+		if now.Sub(e.at) <= d.ttl && d.order.Len() <= d.maxEntries {
+			return
+		}
+		if d.seen[e.key] == e.at {
+			delete(d.seen, e.key)
+		}
+		d.order.Remove(front)
+	}
+}
@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+func TestDedupCache(t *testing.T) {
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	type call struct {
+		key  string
+		at   time.Time
+		want bool
+	}
+	tests := []struct {
+		name       string
+		maxEntries int
+		calls      []call
+	}{
+		{
+			name:       "窗口内重复投递只处理一次",
+			maxEntries: 10,
+			calls: []call{
+				{"a", at(0), false},
+				{"a", at(time.Second), true},
+				{"b", at(time.Second), false},
+				{"a", at(time.Minute), true},
+			},
+		},
+		{
+			name:       "超过 TTL 后重新处理",
+			maxEntries: 10,
+			calls: []call{
+				{"a", at(0), false},
+				{"a", at(time.Minute + time.Second), false},
+				{"a", at(time.Minute + 2*time.Second), true},
+			},
+		},
+		{
+			name:       "超出容量时淘汰最早的记录",
+			maxEntries: 2,
+			calls: []call{
+				{"a", at(0), false},
+				{"b", at(time.Millisecond), false},
+				{"c", at(2 * time.Millisecond), false},
+				{"a", at(3 * time.Millisecond), false},
+				{"c", at(4 * time.Millisecond), true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDedupCache(&types.DedupConfig{TTL: time.Minute, MaxEntries: tt.maxEntries})
+			for i, c := range tt.calls {
+				if got := d.seenBefore(c.key, c.at); got != c.want {
+					t.Fatalf("call %d seenBefore(%s) = %v, want %v", i, c.key, got, c.want)
+				}
+			}
+			if d.order.Len() > tt.maxEntries || len(d.seen) > tt.maxEntries {
+				t.Fatalf("cache holds %d entries (%d keys), limit %d", d.order.Len(), len(d.seen), tt.maxEntries)
+			}
+		})
+	}
+}
+
+func TestDedupCacheExpiresOnNextCall(t *testing.T) {
+	d := newDedupCache(&types.DedupConfig{TTL: time.Minute, MaxEntries: 100})
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		d.seenBefore(string(rune('a'+i)), start)
+	}
+	// 过期记录在下一次调用时一并清理
+	d.seenBefore("new", start.Add(2*time.Minute))
+	if d.order.Len() != 1 || len(d.seen) != 1 {
+		t.Fatalf("cache holds %d entries (%d keys) after expiry, want 1", d.order.Len(), len(d.seen))
+	}
+}
@@ -0,0 +1,112 @@
+package websocket
+
+import "sync"
+
+type dispatchJob struct {
+	key     string
+	fn      func()
+	discard func() // 任务未执行就被丢弃时调用，可以为 nil
+}
+
+// dispatcher 以固定数量的 worker 执行消息处理，serial 时同一 key 的任务按到达顺序串行执行
+type dispatcher struct {
+	serial   bool
+	maxQueue int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*dispatchJob
+	busy   map[string]bool
+	closed bool
+}
+
+func newDispatcher(workers, maxQueue int, serial bool) *dispatcher {
+	d := &dispatcher{
+		serial:   serial,
+		maxQueue: maxQueue,
+		busy:     make(map[string]bool),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// submit 把任务加入队列，队列已满时返回 false 且不调用 discard；已关闭时直接丢弃任务
+func (d *dispatcher) submit(key string, fn, discard func()) bool {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		if discard != nil {
+			discard()
+		}
+		return true
+	}
+	if d.maxQueue > 0 && len(d.queue) >= d.maxQueue {
+		d.mu.Unlock()
+		return false
+	}
+	d.queue = append(d.queue, &dispatchJob{key: key, fn: fn, discard: discard})
+	d.cond.Signal()
+	d.mu.Unlock()
+	return true
+}
+
+// close 停止 worker，并对尚未执行的任务调用 discard
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	d.closed = true
+	queued := d.queue
+	d.queue = nil
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	for _, job := range queued {
+		if job.discard != nil {
+			job.discard()
+		}
+	}
+}
+
+func (d *dispatcher) worker() {
+	for {
+		d.mu.Lock()
+		var job *dispatchJob
+		for {
+			if d.closed {
+				d.mu.Unlock()
+				return
+			}
+			if job = d.next(); job != nil {
+				break
+			}
+			d.cond.Wait()
+		}
+		locked := d.serial && job.key != ""
+		if locked {
+			d.busy[job.key] = true
+		}
+		d.mu.Unlock()
+
+		job.fn()
+
+		if locked {
+			d.mu.Lock()
+			delete(d.busy, job.key)
+			d.cond.Broadcast()
+			d.mu.Unlock()
+		}
+	}
+}
+
+func (d *dispatcher) next() *dispatchJob {
+	for i, job := range d.queue {
+		if d.serial && job.key != "" && d.busy[job.key] {
+			continue
+		}
+		d.queue = append(d.queue[:i], d.queue[i+1:]...)
+		return job
+	}
+	return nil
+}
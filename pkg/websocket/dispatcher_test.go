@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestDispatcherQueueLimit(t *testing.T) {
+	d := newDispatcher(1, 2, false)
+	release := make(chan struct{})
+	running := make(chan struct{})
+	if !d.submit("", func() { close(running); <-release }, nil) {
+		t.Fatal("first job rejected")
+	}
+	<-running
+
+	var discarded sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		discarded.Add(1)
+		if !d.submit("", func() { t.Error("queued job ran after close") }, discarded.Done) {
+			t.Fatalf("job %d rejected below the limit", i)
+		}
+	}
+	if d.submit("", func() {}, func() { t.Error("rejected job discarded") }) {
+		t.Fatal("job accepted over the limit")
+	}
+
+	// 关闭时排队中的任务不执行，但要调用 discard 释放资源
+	d.close()
+	discarded.Wait()
+	close(release)
+
+	called := false
+	d.submit("", func() { t.Error("job ran after close") }, func() { called = true })
+	if !called {
+		t.Fatal("job submitted after close not discarded")
+	}
+}
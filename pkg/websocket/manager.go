@@ -2,69 +2,142 @@ package websocket
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
+	"fmt"
 	"log/slog"
-	"net"
-	"net/url"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
 	"github.com/ystyle/xiaoyi-agent-sdk/pkg/auth"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/store"
 	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
 )
 
-type MessageHandler func(msg *types.A2ARequest)
+type MessageHandler func(ctx context.Context, msg *types.A2ARequest)
 type ClearHandler func(sessionID string)
 type CancelHandler func(sessionID, taskID string)
 type ErrorHandler func(serverID types.ServerID, err error)
 type StateHandler func(serverID types.ServerID, connected bool)
+type ParseWarningHandler func(serverID types.ServerID, warning types.ParseWarning)
+
+// GiveUpHandler 在重连策略放弃时调用，lastErr 为最后一次连接失败的原因
+type GiveUpHandler func(serverID types.ServerID, attempts int, lastErr error)
+
+var errHeartbeatTimeout = errors.New("heartbeat timeout")
+
+type taskContext struct {
+	sessionID string
+	cancel    context.CancelCauseFunc
+	// detached 的任务在处理器返回后保留，直到 FinishTask、tasks/cancel 或清理会话
+	detached bool
+}
 
 type reconnectEvent struct {
-	serverID types.ServerID
-	delay    time.Duration
+	delay time.Duration
+	cause error
 }
 
 type Manager struct {
-	config *types.Config
-	auth   *auth.Auth
+	config    *types.Config
+	auth      *auth.Auth
+	transport types.Transport
+	conns     []*serverConn
 
-	ws1    *websocket.Conn
-	ws2    *websocket.Conn
-	state1 types.ServerState
-	state2 types.ServerState
+	// active 是主备模式下当前使用的接入点，failback 是等待切回 primary 的定时器
+	active   *serverConn
+	failback *time.Timer
+	closed   bool
+	activeMu sync.Mutex
 
-	ws1Mu sync.Mutex
-	ws2Mu sync.Mutex
+	sessions types.SessionStore
 
-	connectedTime1 time.Time
-	connectedTime2 time.Time
+	handlers struct {
+		message  MessageHandler
+		clear    ClearHandler
+		cancel   CancelHandler
+		error    ErrorHandler
+		state    StateHandler
+		warning  ParseWarningHandler
+		route    RouteHandler
+		giveUp   GiveUpHandler
+		failover FailoverHandler
+	}
 
-	sessionServerMap map[string]types.ServerID
-	mu               sync.RWMutex
+	dispatcher *dispatcher
+	dedup      *dedupCache
 
-	handlers struct {
-		message MessageHandler
-		clear   ClearHandler
-		cancel  CancelHandler
-		error   ErrorHandler
-		state   StateHandler
+	metrics struct {
+		duplicates atomic.Uint64
+		rerouted   atomic.Uint64
+		queued     atomic.Uint64
+		expired    atomic.Uint64
 	}
 
-	reconnectChan chan reconnectEvent
-	done          chan struct{}
-	wg            sync.WaitGroup
+	methods   map[string]methodFunc
+	methodsMu sync.RWMutex
+
+	tasks      map[string]*taskContext
+	tasksMu    sync.Mutex
+	baseCtx    context.Context
+	baseCancel context.CancelCauseFunc
+
+	done chan struct{}
+	wg   sync.WaitGroup
 }
 
 func NewManager(cfg *types.Config) *Manager {
-	return &Manager{
-		config:           cfg,
-		auth:             auth.New(cfg.AK, cfg.SK, cfg.AgentID),
-		sessionServerMap: make(map[string]types.ServerID),
-		reconnectChan:    make(chan reconnectEvent, 10),
-		done:             make(chan struct{}),
+	m := &Manager{
+		config:    cfg,
+		auth:      auth.New(cfg.AK, cfg.SK, cfg.AgentID),
+		transport: cfg.Transport,
+		sessions:  cfg.SessionStore,
+		done:      make(chan struct{}),
+		tasks:     make(map[string]*taskContext),
+	}
+	if m.transport == nil {
+		gt := &GorillaTransport{Proxy: cfg.Proxy.ProxyURL, ReadLimit: cfg.ReadLimit}
+		if cfg.Compression != nil {
+			gt.EnableCompression = true
+			gt.CompressionLevel = cfg.Compression.Level
+		}
+		m.transport = gt
+	}
+	if m.sessions == nil {
+		m.sessions = store.NewMemorySessionStore(cfg.SessionTTL, cfg.MaxSessions)
+	}
+	for _, ep := range cfg.ResolveEndpoints() {
+		m.conns = append(m.conns, newServerConn(ep))
+	}
+	m.baseCtx, m.baseCancel = context.WithCancelCause(context.Background())
+	m.methods = map[string]methodFunc{
+		"clearContext":   m.handleClearContext,
+		"tasks/cancel":   m.handleTasksCancel,
+		"message/stream": m.handleUserMessage,
+		"message/send":   m.handleUserMessage,
+	}
+	if cfg.MaxConcurrentHandlers > 0 {
+		m.dispatcher = newDispatcher(cfg.MaxConcurrentHandlers, cfg.MaxQueuedHandlers, cfg.PerSessionSerial)
+	}
+	if cfg.Dedup != nil {
+		m.dedup = newDedupCache(cfg.Dedup)
+	}
+	if cfg.OutboundQueue != nil {
+		for _, s := range m.conns {
+			s.queue = newOutboundQueue(cfg.OutboundQueue, func() bool {
+				return s.current() != nil
+			}, func(f *queuedFrame, err error) {
+				m.metrics.expired.Add(1)
+				slog.Warn("队列帧已过期", "server", s.id, "task", f.msg.TaskID)
+				if m.handlers.error != nil {
+					m.handlers.error(s.id, err)
+				}
+			})
+		}
 	}
+	return m
 }
 
 func (m *Manager) OnMessage(h MessageHandler) {
@@ -87,188 +160,132 @@ func (m *Manager) OnState(h StateHandler) {
 	m.handlers.state = h
 }
 
+func (m *Manager) OnParseWarning(h ParseWarningHandler) {
+	m.handlers.warning = h
+}
+
+func (m *Manager) OnGiveUp(h GiveUpHandler) {
+	m.handlers.giveUp = h
+}
+
 func (m *Manager) Connect(ctx context.Context) error {
-	if m.config.SingleServer {
-		if err := m.connectServer1(ctx); err != nil {
+	if m.config.Standby != nil {
+		if err := m.connectStandby(ctx); err != nil {
 			return types.ErrConnectFailed
 		}
-	} else {
-		var err1, err2 error
-		var wg sync.WaitGroup
-		wg.Add(2)
-
-		go func() {
-			defer wg.Done()
-			err1 = m.connectServer1(ctx)
-		}()
+		m.startLoops()
+		return nil
+	}
 
+	errs := make([]error, len(m.conns))
+	var wg sync.WaitGroup
+	for i, s := range m.conns {
+		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err2 = m.connectServer2(ctx)
+			errs[i] = m.connect(ctx, s)
 		}()
+	}
+	wg.Wait()
 
-		wg.Wait()
-
-		if err1 != nil && err2 != nil {
-			return types.ErrConnectFailed
-		}
+	connected := false
+	for _, err := range errs {
+		connected = connected || err == nil
+	}
+	if !connected {
+		return types.ErrConnectFailed
 	}
 
-	go m.reconnectLoop()
-	go m.startHeartbeat()
+	m.startLoops()
 	return nil
 }
 
-func (m *Manager) connectServer1(ctx context.Context) error {
-	dialer := websocket.Dialer{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: isWssWithIP(m.config.WSUrl1),
-		},
-		HandshakeTimeout: types.ConnectionTimeout,
+// startLoops 为每个接入点启动独立的重连循环，一个接入点的退避等待不会推迟其他接入点
+func (m *Manager) startLoops() {
+	m.wg.Add(len(m.conns) + 1)
+	for _, s := range m.conns {
+		go m.reconnectLoop(s)
 	}
+	go m.startHeartbeat()
+}
 
+func (m *Manager) connect(ctx context.Context, s *serverConn) error {
 	headers := m.auth.Headers()
-	conn, _, err := dialer.Dial(m.config.WSUrl1, mapToHeader(headers))
+	conn, err := m.transport.Dial(ctx, s.endpoint, mapToHeader(headers), s.touchHeartbeat)
 	if err != nil {
 		if m.handlers.error != nil {
-			m.handlers.error(types.Server1, err)
+			m.handlers.error(s.id, err)
 		}
 		return err
 	}
 
-	conn.SetPongHandler(func(appData string) error {
-		m.ws1Mu.Lock()
-		m.state1.LastHeartbeat = time.Now().Unix()
-		m.ws1Mu.Unlock()
-		return nil
-	})
-
-	m.ws1Mu.Lock()
-	m.ws1 = conn
-	m.state1.Connected = true
-	m.state1.Ready = true
-	m.state1.LastHeartbeat = time.Now().Unix()
-	m.connectedTime1 = time.Now()
-	m.ws1Mu.Unlock()
-
+	s.attach(conn)
 	if m.handlers.state != nil {
-		m.handlers.state(types.Server1, true)
+		m.handlers.state(s.id, true)
 	}
 
 	initMsg := protocol.BuildInitMessage(m.config.AgentID)
-	m.sendToServer1(initMsg)
+	s.send(initMsg)
+	m.flushQueue(s.id)
 
-	go m.readLoop(conn, types.Server1)
-	go m.pingLoop(conn, types.Server1)
+	m.wg.Add(2)
+	go m.readLoop(s, conn)
+	go m.pingLoop(s, conn)
 
 	return nil
 }
 
-func (m *Manager) connectServer2(ctx context.Context) error {
-	dialer := websocket.Dialer{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: isWssWithIP(m.config.WSUrl2),
-		},
-		HandshakeTimeout: types.ConnectionTimeout,
-	}
-
-	headers := m.auth.Headers()
-	conn, _, err := dialer.Dial(m.config.WSUrl2, mapToHeader(headers))
-	if err != nil {
-		if m.handlers.error != nil {
-			m.handlers.error(types.Server2, err)
+func (m *Manager) conn(id types.ServerID) *serverConn {
+	for _, s := range m.conns {
+		if s.id == id {
+			return s
 		}
-		return err
-	}
-
-	conn.SetPongHandler(func(appData string) error {
-		m.ws2Mu.Lock()
-		m.state2.LastHeartbeat = time.Now().Unix()
-		m.ws2Mu.Unlock()
-		return nil
-	})
-
-	m.ws2Mu.Lock()
-	m.ws2 = conn
-	m.state2.Connected = true
-	m.state2.Ready = true
-	m.state2.LastHeartbeat = time.Now().Unix()
-	m.connectedTime2 = time.Now()
-	m.ws2Mu.Unlock()
-
-	if m.handlers.state != nil {
-		m.handlers.state(types.Server2, true)
 	}
-
-	initMsg := protocol.BuildInitMessage(m.config.AgentID)
-	m.sendToServer2(initMsg)
-
-	go m.readLoop(conn, types.Server2)
-	go m.pingLoop(conn, types.Server2)
-
 	return nil
 }
 
-func (m *Manager) sendToServer1(msg *types.OutboundMessage) error {
-	m.ws1Mu.Lock()
-	defer m.ws1Mu.Unlock()
-	if m.ws1 == nil {
-		return types.ErrServerNotReady
+func (m *Manager) servers() []types.ServerID {
+	ids := make([]types.ServerID, len(m.conns))
+	for i, s := range m.conns {
+		ids[i] = s.id
 	}
-	data, err := protocol.Marshal(msg)
-	if err != nil {
-		return err
-	}
-	slog.Debug("发送消息", "server", "server1", "data", string(data))
-	return m.ws1.WriteMessage(websocket.TextMessage, data)
+	return ids
 }
 
-func (m *Manager) sendToServer2(msg *types.OutboundMessage) error {
-	m.ws2Mu.Lock()
-	defer m.ws2Mu.Unlock()
-	if m.ws2 == nil {
+func (m *Manager) sendTo(target types.ServerID, msg *types.OutboundMessage) error {
+	s := m.conn(target)
+	if s == nil {
 		return types.ErrServerNotReady
 	}
-	data, err := protocol.Marshal(msg)
-	if err != nil {
-		return err
-	}
-	slog.Debug("发送消息", "server", "server2", "data", string(data))
-	return m.ws2.WriteMessage(websocket.TextMessage, data)
+	return s.send(msg)
 }
 
-func (m *Manager) readLoop(conn *websocket.Conn, id types.ServerID) {
-	m.wg.Add(1)
+func (m *Manager) readLoop(s *serverConn, conn types.Conn) {
 	defer m.wg.Done()
 
 	for {
-		select {
-		case <-m.done:
-			return
-		default:
-			_, data, err := conn.ReadMessage()
-			if err != nil {
-				delay := time.Duration(0)
-				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-					slog.Info("服务器关闭连接", "server", id, "code", 1000)
-					delay = 5 * time.Second
-				} else {
-					slog.Warn("连接断开", "server", id, "error", err)
-				}
-				m.cleanupConnection(id)
-				select {
-				case m.reconnectChan <- reconnectEvent{serverID: id, delay: delay}:
-				case <-m.done:
-				}
+		data, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-m.done:
 				return
+			default:
+			}
+			delay := time.Duration(0)
+			if isNormalClosure(err) {
+				slog.Info("服务器关闭连接", "server", s.id, "code", types.CloseNormalClosure)
+				delay = 5 * time.Second
+			} else {
+				slog.Warn("连接断开", "server", s.id, "error", err)
 			}
-			m.handleMessage(data, id)
+			m.disconnect(s, conn, delay, err)
+			return
 		}
+		m.handleMessage(data, s.id)
 	}
 }
 
-func (m *Manager) pingLoop(conn *websocket.Conn, id types.ServerID) {
-	m.wg.Add(1)
+func (m *Manager) pingLoop(s *serverConn, conn types.Conn) {
 	defer m.wg.Done()
 
 	ticker := time.NewTicker(types.ProtocolHeartbeat)
@@ -279,106 +296,86 @@ func (m *Manager) pingLoop(conn *websocket.Conn, id types.ServerID) {
 		case <-m.done:
 			return
 		case <-ticker.C:
-			m.ws1Mu.Lock()
-			if id == types.Server1 && m.ws1 == conn {
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					m.ws1Mu.Unlock()
-					m.triggerReconnect(id, 0)
-					return
-				}
-				if time.Since(time.Unix(m.state1.LastHeartbeat, 0)) > types.HeartbeatTimeout {
-					m.ws1Mu.Unlock()
-					m.triggerReconnect(id, 0)
-					return
-				}
+			if s.current() != conn {
+				return
 			}
-			m.ws1Mu.Unlock()
-
-			m.ws2Mu.Lock()
-			if id == types.Server2 && m.ws2 == conn {
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					m.ws2Mu.Unlock()
-					m.triggerReconnect(id, 0)
-					return
-				}
-				if time.Since(time.Unix(m.state2.LastHeartbeat, 0)) > types.HeartbeatTimeout {
-					m.ws2Mu.Unlock()
-					m.triggerReconnect(id, 0)
-					return
-				}
+			if err := s.ping(conn); err != nil {
+				m.disconnect(s, conn, 0, err)
+				return
+			}
+			if s.heartbeatExpired() {
+				m.disconnect(s, conn, 0, errHeartbeatTimeout)
+				return
 			}
-			m.ws2Mu.Unlock()
 		}
 	}
 }
 
-func (m *Manager) triggerReconnect(id types.ServerID, delay time.Duration) {
-	m.cleanupConnection(id)
-	select {
-	case m.reconnectChan <- reconnectEvent{serverID: id, delay: delay}:
-	case <-m.done:
-	}
-}
-
-func (m *Manager) cleanupConnection(id types.ServerID) {
-	if id == types.Server1 {
-		m.ws1Mu.Lock()
-		m.state1.Connected = false
-		m.state1.Ready = false
-		if m.ws1 != nil {
-			m.ws1.Close()
-			m.ws1 = nil
-		}
-		m.ws1Mu.Unlock()
-	} else {
-		m.ws2Mu.Lock()
-		m.state2.Connected = false
-		m.state2.Ready = false
-		if m.ws2 != nil {
-			m.ws2.Close()
-			m.ws2 = nil
-		}
-		m.ws2Mu.Unlock()
+// disconnect 关闭 conn 并安排重连，conn 已被替换或已由其他 goroutine 处理时不做任何事
+func (m *Manager) disconnect(s *serverConn, conn types.Conn, delay time.Duration, cause error) {
+	if !s.detach(conn) {
+		return
 	}
-
 	if m.handlers.state != nil {
-		m.handlers.state(id, false)
+		m.handlers.state(s.id, false)
 	}
+	s.scheduleReconnect(reconnectEvent{delay: delay, cause: cause})
 }
 
-func (m *Manager) reconnectLoop() {
-	m.wg.Add(1)
+func (m *Manager) reconnectLoop(s *serverConn) {
 	defer m.wg.Done()
 
 	for {
 		select {
 		case <-m.done:
 			return
-		case event := <-m.reconnectChan:
-			m.doReconnect(event.serverID, event.delay)
+		case event := <-s.reconnect:
+			m.doReconnect(s, event)
 		}
 	}
 }
 
-func (m *Manager) doReconnect(id types.ServerID, extraDelay time.Duration) {
-	state := &m.state1
-	if id == types.Server2 {
-		state = &m.state2
+func (m *Manager) reconnectPolicy() types.ReconnectPolicy {
+	if m.config.ReconnectPolicy != nil {
+		return m.config.ReconnectPolicy
 	}
+	return types.DefaultReconnectPolicy(m.config.ReconnectDelay)
+}
 
-	if state.ReconnectCount >= types.MaxReconnectAttempts {
-		slog.Error("重连次数已达上限", "server", id, "attempts", state.ReconnectCount)
+func (m *Manager) doReconnect(s *serverConn, event reconnectEvent) {
+	if s.current() != nil {
 		return
 	}
+	if m.config.Standby != nil {
+		target, switched := m.standbyTarget(s, event.cause)
+		if target == nil {
+			return
+		}
+		if switched {
+			// 切换后立即连接新的接入点，失败时按正常重连流程继续
+			m.reconnectNow(target)
+			return
+		}
+	}
 
-	delay := m.config.ReconnectDelay * time.Duration(1<<uint(state.ReconnectCount))
-	if delay > types.ReconnectMaxDelay {
-		delay = types.ReconnectMaxDelay
+	attempt := s.nextAttempt()
+	prev := s.lastDelay
+	if attempt == 1 {
+		prev = 0
+	}
+	delay, ok := m.reconnectPolicy().Next(attempt, prev)
+	if !ok {
+		s.giveUp()
+		slog.Error("重连次数已达上限", "server", s.id, "attempts", attempt-1, "error", event.cause)
+		if m.handlers.giveUp != nil {
+			m.handlers.giveUp(s.id, attempt-1, event.cause)
+		}
+		return
 	}
-	delay += extraDelay
-	state.ReconnectCount++
+	s.lastDelay = delay
+	delay += event.delay
 
-	slog.Info("准备重连", "server", id, "attempt", state.ReconnectCount, "delay", delay)
+	slog.Info("准备重连", "server", s.id, "attempt", attempt, "delay", delay)
 
 	select {
 	case <-m.done:
@@ -386,47 +383,55 @@ func (m *Manager) doReconnect(id types.ServerID, extraDelay time.Duration) {
 	case <-time.After(delay):
 	}
 
-	var err error
-	if id == types.Server1 {
-		err = m.connectServer1(context.Background())
-	} else {
-		err = m.connectServer2(context.Background())
-	}
+	m.reconnectNow(s)
+}
 
-	if err != nil {
-		slog.Error("重连失败", "server", id, "error", err)
-		select {
-		case m.reconnectChan <- reconnectEvent{serverID: id, delay: 0}:
-		case <-m.done:
-		}
+func (m *Manager) reconnectNow(s *serverConn) {
+	if err := m.connect(m.baseCtx, s); err != nil {
+		slog.Error("重连失败", "server", s.id, "error", err)
+		s.scheduleReconnect(reconnectEvent{cause: err})
 		return
 	}
 
-	slog.Info("重连成功", "server", id)
+	slog.Info("重连成功", "server", s.id)
 
 	time.AfterFunc(types.StableThreshold, func() {
-		if id == types.Server1 {
-			m.ws1Mu.Lock()
-			stable := m.state1.Connected && m.ws1 != nil
-			m.ws1Mu.Unlock()
-			if stable {
-				slog.Info("连接稳定", "server", id)
-				state.ReconnectCount = 0
-			}
-		} else {
-			m.ws2Mu.Lock()
-			stable := m.state2.Connected && m.ws2 != nil
-			m.ws2Mu.Unlock()
-			if stable {
-				slog.Info("连接稳定", "server", id)
-				state.ReconnectCount = 0
-			}
+		if s.resetAttempts(true) {
+			slog.Info("连接稳定", "server", s.id)
 		}
 	})
+	if m.config.Standby != nil {
+		m.scheduleFailback(s)
+	}
+}
+
+// Reconnect 手动重连指定服务器：已连接时断开后立即重连，已放弃重连时重新开始；重连计数清零
+func (m *Manager) Reconnect(id types.ServerID) error {
+	s := m.conn(id)
+	if s == nil {
+		return &types.XiaoYiError{Code: types.ErrConfigInvalid.Code, Message: "unknown server: " + string(id)}
+	}
+	select {
+	case <-m.done:
+		return types.ErrClientClosed
+	default:
+	}
+
+	if m.config.Standby != nil && s != m.activeConn() {
+		return &types.XiaoYiError{Code: types.ErrConfigInvalid.Code, Message: "endpoint is on standby: " + string(id)}
+	}
+
+	s.resetAttempts(false)
+	if conn := s.current(); conn != nil {
+		// 关闭连接后由 readLoop 触发重连
+		conn.Close()
+		return nil
+	}
+	s.scheduleReconnect(reconnectEvent{})
+	return nil
 }
 
 func (m *Manager) startHeartbeat() {
-	m.wg.Add(1)
 	defer m.wg.Done()
 
 	ticker := time.NewTicker(types.AppHeartbeat)
@@ -438,166 +443,291 @@ func (m *Manager) startHeartbeat() {
 			return
 		case <-ticker.C:
 			hb := protocol.BuildHeartbeatMessage(m.config.AgentID)
-			m.sendToServer1(hb)
-			if !m.config.SingleServer {
-				m.sendToServer2(hb)
+			for _, s := range m.conns {
+				s.send(hb)
 			}
 		}
 	}
 }
 
 func (m *Manager) handleMessage(data []byte, sourceServer types.ServerID) {
-	msg, err := protocol.ParseA2ARequest(data)
+	msg, warnings, err := protocol.ParseA2ARequest(data)
 	if err != nil {
 		if m.handlers.error != nil {
 			m.handlers.error(sourceServer, err)
 		}
 		return
 	}
+	for _, w := range warnings {
+		slog.Warn("消息 part 解析异常", "server", sourceServer, "warning", w.String())
+		if m.handlers.warning != nil {
+			m.handlers.warning(sourceServer, w)
+		}
+	}
+	msg.ServerID = sourceServer
+
+	if m.isDuplicate(msg) {
+		m.metrics.duplicates.Add(1)
+		slog.Info("丢弃重复请求", "server", sourceServer, "method", msg.Method, "id", msg.ID, "task", msg.TaskID())
+		return
+	}
 
 	sessionID := msg.SessionID()
 	if sessionID != "" {
-		m.mu.Lock()
-		m.sessionServerMap[sessionID] = sourceServer
-		m.mu.Unlock()
+		if err := m.sessions.Set(sessionID, sourceServer); err != nil {
+			slog.Warn("会话绑定保存失败", "session", sessionID, "error", err)
+		}
 	}
 
-	if msg.Method == "clearContext" {
-		if m.handlers.clear != nil {
-			m.handlers.clear(sessionID)
-		}
-		m.sendClearContextResponse(msg.ID, sessionID, true, sourceServer)
-		m.mu.Lock()
-		delete(m.sessionServerMap, sessionID)
-		m.mu.Unlock()
+	m.methodsMu.RLock()
+	handle, ok := m.methods[msg.Method]
+	m.methodsMu.RUnlock()
+	if !ok {
+		slog.Warn("未知方法", "server", sourceServer, "method", msg.Method)
+		m.sendRPCError(msg, sourceServer, types.RPCMethodNotFound, "Method not found: "+msg.Method)
 		return
 	}
+	handle(msg, sourceServer)
+}
 
-	if msg.Method == "tasks/cancel" {
-		taskID := msg.Params.ID
-		if m.handlers.cancel != nil {
-			m.handlers.cancel(sessionID, taskID)
-		}
-		m.sendTasksCancelResponse(msg.ID, sessionID, true, sourceServer)
-		return
+func (m *Manager) isDuplicate(msg *types.A2ARequest) bool {
+	if m.dedup == nil {
+		return false
 	}
+	key := dedupKey(msg)
+	return key != "" && m.dedup.seenBefore(key, msg.ReceivedAt)
+}
 
-	if m.handlers.message != nil {
-		m.handlers.message(msg)
+func (m *Manager) startTask(sessionID, taskID string) (context.Context, *taskContext) {
+	ctx, cancel := context.WithCancelCause(m.baseCtx)
+	task := &taskContext{sessionID: sessionID, cancel: cancel}
+	if taskID != "" {
+		m.tasksMu.Lock()
+		m.tasks[taskID] = task
+		m.tasksMu.Unlock()
 	}
+	return ctx, task
 }
 
-func (m *Manager) SendResponse(taskID, sessionID string, response *types.JsonRpcResponse) error {
-	m.mu.RLock()
-	serverID, ok := m.sessionServerMap[sessionID]
-	m.mu.RUnlock()
+func (m *Manager) finishTask(taskID string, task *taskContext) {
+	m.tasksMu.Lock()
+	if m.tasks[taskID] == task {
+		delete(m.tasks, taskID)
+	}
+	m.tasksMu.Unlock()
+	task.cancel(nil)
+}
 
-	if !ok {
-		return types.ErrSessionNotFound
+// releaseTask 在处理器返回时结束未分离的任务
+func (m *Manager) releaseTask(taskID string, task *taskContext) {
+	m.tasksMu.Lock()
+	detached := task.detached
+	m.tasksMu.Unlock()
+	if !detached {
+		m.finishTask(taskID, task)
 	}
+}
 
-	msg := protocol.BuildResponseMessage(m.config.AgentID, sessionID, taskID, response)
-	if serverID == types.Server2 {
-		return m.sendToServer2(msg)
+// DetachTask 使任务的 ctx 在处理器返回后继续有效，tasks/cancel 仍可取消它；
+// 任务不存在（处理器已返回或已取消）时返回 false
+func (m *Manager) DetachTask(taskID string) bool {
+	m.tasksMu.Lock()
+	defer m.tasksMu.Unlock()
+	task, ok := m.tasks[taskID]
+	if ok {
+		task.detached = true
 	}
-	return m.sendToServer1(msg)
+	return ok
 }
 
-func (m *Manager) sendClearContextResponse(requestID, sessionID string, success bool, target types.ServerID) {
-	resp := protocol.BuildClearContextResponse(requestID, success)
-	msg := protocol.BuildResponseMessage(m.config.AgentID, sessionID, requestID, resp)
+// FinishTask 结束分离的任务并取消其 ctx
+func (m *Manager) FinishTask(taskID string) {
+	m.tasksMu.Lock()
+	task, ok := m.tasks[taskID]
+	m.tasksMu.Unlock()
+	if ok {
+		m.finishTask(taskID, task)
+	}
+}
 
-	if target == types.Server2 {
-		m.sendToServer2(msg)
-	} else {
-		m.sendToServer1(msg)
+func (m *Manager) cancelTask(taskID string, cause error) bool {
+	m.tasksMu.Lock()
+	task, ok := m.tasks[taskID]
+	delete(m.tasks, taskID)
+	m.tasksMu.Unlock()
+	if !ok {
+		return false
 	}
+	task.cancel(cause)
+	return true
 }
 
-func (m *Manager) sendTasksCancelResponse(requestID, sessionID string, success bool, target types.ServerID) {
-	resp := protocol.BuildTasksCancelResponse(requestID, success)
-	msg := protocol.BuildResponseMessage(m.config.AgentID, sessionID, requestID, resp)
+func (m *Manager) cancelSessionTasks(sessionID string, cause error) {
+	m.tasksMu.Lock()
+	var canceled []*taskContext
+	for taskID, task := range m.tasks {
+		if task.sessionID == sessionID {
+			canceled = append(canceled, task)
+			delete(m.tasks, taskID)
+		}
+	}
+	m.tasksMu.Unlock()
+	for _, task := range canceled {
+		task.cancel(cause)
+	}
+}
 
-	if target == types.Server2 {
-		m.sendToServer2(msg)
-	} else {
-		m.sendToServer1(msg)
+// dispatch 执行或排队处理 msg 的 fn，排队已满时以 RPCServerBusy 拒绝并调用 discard
+func (m *Manager) dispatch(msg *types.A2ARequest, source types.ServerID, fn, discard func()) {
+	if m.dispatcher == nil {
+		fn()
+		return
+	}
+	if m.dispatcher.submit(msg.SessionID(), fn, discard) {
+		return
+	}
+	slog.Warn("消息处理队列已满，拒绝请求", "server", source, "method", msg.Method, "id", msg.ID)
+	if discard != nil {
+		discard()
 	}
+	m.sendRPCError(msg, source, types.RPCServerBusy, "Server busy")
 }
 
-func (m *Manager) IsReady() bool {
-	m.ws1Mu.Lock()
-	ws1ok := m.state1.Ready && m.ws1 != nil
-	m.ws1Mu.Unlock()
+func (m *Manager) SendResponse(taskID, sessionID string, response *types.JsonRpcResponse) error {
+	_, err := m.SendResponseVia(context.Background(), taskID, sessionID, response)
+	return err
+}
 
-	if m.config.SingleServer {
-		return ws1ok
+// SendResponseVia 按路由策略发送回复，返回最终送达的服务器；
+// 启用重连队列时帧可能暂存在队列中，ctx 带有 WithWaitFlushed 时等待帧被写出
+func (m *Manager) SendResponseVia(ctx context.Context, taskID, sessionID string, response *types.JsonRpcResponse) (types.ServerID, error) {
+	serverID, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if m.config.Standby != nil {
+		// 主备模式下只有当前接入点在线，会话随切换迁移到当前接入点
+		if active := m.activeConn(); active != nil && active.id != serverID {
+			serverID = active.id
+			if err := m.sessions.Set(sessionID, serverID); err != nil {
+				slog.Warn("会话绑定保存失败", "session", sessionID, "error", err)
+			}
+		}
 	}
 
-	m.ws2Mu.Lock()
-	ws2ok := m.state2.Ready && m.ws2 != nil
-	m.ws2Mu.Unlock()
-
-	return ws1ok || ws2ok
+	msg := protocol.BuildResponseMessage(m.config.AgentID, sessionID, taskID, response)
+	if err := m.checkFrame(msg); err != nil {
+		return "", err
+	}
+	delivered, err := m.route(ctx, sessionID, serverID, msg)
+	if err == nil {
+		m.sessions.Touch(sessionID)
+	}
+	return delivered, err
 }
 
-func (m *Manager) GetState() *types.ConnectionState {
-	return &types.ConnectionState{
-		Connected:      m.state1.Connected || m.state2.Connected,
-		Authenticated:  m.state1.Connected || m.state2.Connected,
-		LastHeartbeat:  maxInt64(m.state1.LastHeartbeat, m.state2.LastHeartbeat),
-		ReconnectCount: maxInt(m.state1.ReconnectCount, m.state2.ReconnectCount),
-		Server1Ready:   m.state1.Ready,
-		Server2Ready:   m.state2.Ready,
+// checkFrame 在设置了 MaxFrameBytes 时拒绝编码后超出上限的帧
+func (m *Manager) checkFrame(msg *types.OutboundMessage) error {
+	if m.config.MaxFrameBytes <= 0 {
+		return nil
 	}
+	data, err := protocol.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(data) > m.config.MaxFrameBytes {
+		return &types.XiaoYiError{
+			Code:    types.ErrFrameTooLarge.Code,
+			Message: fmt.Sprintf("frame is %d bytes, limit %d", len(data), m.config.MaxFrameBytes),
+		}
+	}
+	return nil
 }
 
-func (m *Manager) Close() {
-	close(m.done)
-	m.ws1Mu.Lock()
-	if m.ws1 != nil {
-		m.ws1.Close()
-	}
-	m.ws1Mu.Unlock()
+func (m *Manager) sendClearContextResponse(requestID, sessionID string, success bool, target types.ServerID) {
+	resp := protocol.BuildClearContextResponse(requestID, success)
+	msg := protocol.BuildResponseMessage(m.config.AgentID, sessionID, requestID, resp)
+	m.sendTo(target, msg)
+}
+
+func (m *Manager) sendTasksCancelResponse(requestID, sessionID, taskID string, success bool, target types.ServerID) {
+	resp := protocol.BuildTasksCancelResponse(requestID, taskID, success)
+	msg := protocol.BuildResponseMessage(m.config.AgentID, sessionID, taskID, resp)
+	m.sendTo(target, msg)
+}
 
-	m.ws2Mu.Lock()
-	if m.ws2 != nil {
-		m.ws2.Close()
+func (m *Manager) IsReady() bool {
+	for _, s := range m.conns {
+		if s.ready() {
+			return true
+		}
 	}
-	m.ws2Mu.Unlock()
+	return false
+}
 
-	m.wg.Wait()
+func (m *Manager) GetState() *types.ConnectionState {
+	state := &types.ConnectionState{}
+	for _, s := range m.conns {
+		ss := s.snapshot()
+		state.Connected = state.Connected || ss.Connected
+		state.LastHeartbeat = max(state.LastHeartbeat, ss.LastHeartbeat)
+		state.ReconnectCount = max(state.ReconnectCount, ss.ReconnectCount)
+		switch s.id {
+		case types.Server1:
+			state.Server1Ready = ss.Ready
+		case types.Server2:
+			state.Server2Ready = ss.Ready
+		}
+		state.Endpoints = append(state.Endpoints, types.EndpointState{
+			ID:          s.id,
+			URL:         s.endpoint.URL,
+			Role:        s.endpoint.Role,
+			ServerState: ss,
+		})
+	}
+	state.Authenticated = state.Connected
+	return state
 }
 
-func isWssWithIP(wsUrl string) bool {
-	u, err := url.Parse(wsUrl)
-	if err != nil {
-		return false
-	}
-	if u.Scheme != "wss" {
-		return false
+func (m *Manager) Metrics() types.Metrics {
+	return types.Metrics{
+		DuplicatesDropped: m.metrics.duplicates.Load(),
+		Rerouted:          m.metrics.rerouted.Load(),
+		FramesQueued:      m.metrics.queued.Load(),
+		FramesExpired:     m.metrics.expired.Load(),
+		ActiveSessions:    m.sessions.Len(),
 	}
-	return net.ParseIP(u.Hostname()) != nil
 }
 
-func mapToHeader(m map[string]string) map[string][]string {
-	h := make(map[string][]string)
-	for k, v := range m {
-		h[k] = []string{v}
+func (m *Manager) Close() {
+	m.activeMu.Lock()
+	if m.closed {
+		m.activeMu.Unlock()
+		return
 	}
-	return h
-}
+	m.closed = true
+	if m.failback != nil {
+		m.failback.Stop()
+		m.failback = nil
+	}
+	m.activeMu.Unlock()
 
-func maxInt64(a, b int64) int64 {
-	if a > b {
-		return a
+	close(m.done)
+	m.baseCancel(types.ErrClientClosed)
+	if m.dispatcher != nil {
+		m.dispatcher.close()
+	}
+	for _, s := range m.conns {
+		s.detach(nil)
 	}
-	return b
+
+	m.wg.Wait()
 }
 
-func maxInt(a, b int) int {
-	if a > b {
-		return a
+func mapToHeader(m map[string]string) http.Header {
+	h := make(http.Header)
+	for k, v := range m {
+		h.Set(k, v)
 	}
-	return b
+	return h
 }
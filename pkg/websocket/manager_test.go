@@ -0,0 +1,345 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/websocket"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/xiaoyitest"
+)
+
+const (
+	testAK    = "ak"
+	testSK    = "sk"
+	testAgent = "agent"
+)
+
+// transports 让每个用例分别经过真实 WebSocket 与内存管道运行
+var transports = []struct {
+	name   string
+	config func(srv *xiaoyitest.Server, cfg *types.Config)
+}{
+	{"websocket", func(srv *xiaoyitest.Server, cfg *types.Config) { cfg.WSUrl1 = srv.URL() }},
+	{"pipe", func(srv *xiaoyitest.Server, cfg *types.Config) { cfg.Transport = srv.Transport() }},
+}
+
+func newManager(t *testing.T, srv *xiaoyitest.Server, setup func(cfg *types.Config)) *websocket.Manager {
+	t.Helper()
+	cfg := &types.Config{
+		AK: testAK, SK: testSK, AgentID: testAgent,
+		SingleServer:    true,
+		ReconnectPolicy: &types.ConstantBackoff{Delay: 10 * time.Millisecond},
+	}
+	setup(cfg)
+	cfg.ApplyDefaults()
+	m := websocket.NewManager(cfg)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// echo 把每条用户消息的文本原样回复
+func echo(m *websocket.Manager) {
+	m.OnMessage(func(ctx context.Context, msg *types.A2ARequest) {
+		resp := protocol.BuildArtifactResponse(protocol.GenerateID(), msg.TaskID(), []types.Part{types.NewTextPart(msg.Text())}, true, false)
+		m.SendResponse(msg.TaskID(), msg.SessionID(), resp)
+	})
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func waitInits(t *testing.T, ctx context.Context, srv *xiaoyitest.Server, n int) {
+	t.Helper()
+	for srv.Inits() < n || srv.Connections() == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("waiting for %d inits: got %d", n, srv.Inits())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func roundTrip(t *testing.T, ctx context.Context, srv *xiaoyitest.Server, sessionID, taskID, text string) {
+	t.Helper()
+	if _, err := srv.SendMessage(sessionID, taskID, text); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool { return r.TaskID == taskID })
+	if err != nil {
+		t.Fatalf("no response for %s: %v", taskID, err)
+	}
+	if got := resp.Text(); got != text {
+		t.Fatalf("response text = %q, want %q", got, text)
+	}
+}
+
+func TestSendAndReceive(t *testing.T) {
+	for _, tr := range transports {
+		t.Run(tr.name, func(t *testing.T) {
+			srv := xiaoyitest.NewServer(testAK, testSK, testAgent)
+			defer srv.Close()
+			m := newManager(t, srv, func(cfg *types.Config) { tr.config(srv, cfg) })
+			echo(m)
+
+			ctx := testContext(t)
+			if err := m.Connect(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := srv.WaitConnected(ctx); err != nil {
+				t.Fatal(err)
+			}
+			roundTrip(t, ctx, srv, "s1", "t1", "你好")
+			if !m.GetState().Connected {
+				t.Fatal("state not connected")
+			}
+		})
+	}
+}
+
+func TestReconnectAfterDisconnect(t *testing.T) {
+	for _, tr := range transports {
+		t.Run(tr.name, func(t *testing.T) {
+			srv := xiaoyitest.NewServer(testAK, testSK, testAgent)
+			defer srv.Close()
+			m := newManager(t, srv, func(cfg *types.Config) { tr.config(srv, cfg) })
+			echo(m)
+
+			var mu sync.Mutex
+			var states []bool
+			m.OnState(func(id types.ServerID, connected bool) {
+				mu.Lock()
+				states = append(states, connected)
+				mu.Unlock()
+			})
+
+			ctx := testContext(t)
+			if err := m.Connect(ctx); err != nil {
+				t.Fatal(err)
+			}
+			waitInits(t, ctx, srv, 1)
+			roundTrip(t, ctx, srv, "s1", "before", "before")
+
+			srv.Disconnect()
+			waitInits(t, ctx, srv, 2)
+			roundTrip(t, ctx, srv, "s1", "after", "after")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if fmt.Sprint(states) != "[true false true]" {
+				t.Fatalf("state changes = %v", states)
+			}
+		})
+	}
+}
+
+func TestManualReconnect(t *testing.T) {
+	srv := xiaoyitest.NewServer(testAK, testSK, testAgent)
+	defer srv.Close()
+	m := newManager(t, srv, func(cfg *types.Config) { cfg.Transport = srv.Transport() })
+
+	ctx := testContext(t)
+	if err := m.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitInits(t, ctx, srv, 1)
+	if err := m.Reconnect(types.Server1); err != nil {
+		t.Fatal(err)
+	}
+	waitInits(t, ctx, srv, 2)
+	if err := m.Reconnect("nope"); err == nil {
+		t.Fatal("Reconnect accepted unknown server")
+	}
+}
+
+// TestConcurrentSendDuringReconnect 在断线重连期间并发发送，启用出站队列后所有帧都应送达
+func TestConcurrentSendDuringReconnect(t *testing.T) {
+	for _, tr := range transports {
+		t.Run(tr.name, func(t *testing.T) {
+			srv := xiaoyitest.NewServer(testAK, testSK, testAgent)
+			defer srv.Close()
+			m := newManager(t, srv, func(cfg *types.Config) {
+				tr.config(srv, cfg)
+				cfg.OutboundQueue = &types.QueueConfig{MaxAge: 5 * time.Second}
+			})
+			echo(m)
+			disconnected := make(chan struct{}, 1)
+			m.OnState(func(id types.ServerID, connected bool) {
+				if !connected {
+					disconnected <- struct{}{}
+				}
+			})
+
+			ctx := testContext(t)
+			if err := m.Connect(ctx); err != nil {
+				t.Fatal(err)
+			}
+			waitInits(t, ctx, srv, 1)
+			roundTrip(t, ctx, srv, "s1", "bind", "bind")
+
+			// 等客户端发现断线后再发送：已写入内核缓冲但对端已关闭的帧无法感知，不在本用例范围内
+			srv.Disconnect()
+			<-disconnected
+
+			const senders, perSender = 4, 25
+			var wg sync.WaitGroup
+			for i := range senders {
+				wg.Go(func() {
+					for j := range perSender {
+						taskID := fmt.Sprintf("t%d-%d", i, j)
+						resp := protocol.BuildStatusResponse(protocol.GenerateID(), taskID, "ok", "completed")
+						if err := m.SendResponse(taskID, "s1", resp); err != nil {
+							t.Errorf("send %s: %v", taskID, err)
+						}
+					}
+				})
+			}
+			wg.Wait()
+
+			if _, err := srv.WaitResponses(ctx, 1+senders*perSender); err != nil {
+				t.Fatalf("got %d responses: %v metrics=%+v", len(srv.Responses()), err, m.Metrics())
+			}
+		})
+	}
+}
+
+func TestPipeClose(t *testing.T) {
+	a, b := websocket.Pipe()
+	if err := a.WriteMessage([]byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	data, err := b.ReadMessage()
+	if err != nil || string(data) != "hi" {
+		t.Fatalf("ReadMessage = %q, %v", data, err)
+	}
+	if _, err := b.ReadMessage(); err != io.EOF {
+		t.Fatalf("read after peer close = %v, want io.EOF", err)
+	}
+	if err := b.WriteMessage([]byte("x")); err == nil {
+		t.Fatal("write to closed peer succeeded")
+	}
+}
+
+func TestHandlerQueueLimit(t *testing.T) {
+	srv := xiaoyitest.NewServer(testAK, testSK, testAgent)
+	defer srv.Close()
+	m := newManager(t, srv, func(cfg *types.Config) {
+		cfg.Transport = srv.Transport()
+		cfg.MaxConcurrentHandlers = 1
+		cfg.MaxQueuedHandlers = 1
+	})
+	started := make(chan string, 3)
+	release := make(chan struct{})
+	m.OnMessage(func(ctx context.Context, msg *types.A2ARequest) {
+		started <- msg.TaskID()
+		<-release
+	})
+	defer close(release)
+
+	ctx := testContext(t)
+	if err := m.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// t1 占用唯一的 worker，t2 排队，t3 超出队列上限
+	srv.SendMessage("s1", "t1", "1")
+	if id := <-started; id != "t1" {
+		t.Fatalf("started %s, want t1", id)
+	}
+	srv.SendMessage("s2", "t2", "2")
+	srv.SendMessage("s3", "t3", "3")
+
+	resp, err := srv.WaitResponse(ctx, func(r *xiaoyitest.Response) bool { return r.Error != nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TaskID != "t3" || fmt.Sprint(resp.Error.Code) != fmt.Sprint(types.RPCServerBusy) {
+		t.Fatalf("rejected %s with %+v, want t3 with %d", resp.TaskID, resp.Error, types.RPCServerBusy)
+	}
+}
+
+func TestCloseDuringFailback(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		srv := xiaoyitest.NewServer(testAK, testSK, testAgent)
+		m := newManager(t, srv, func(cfg *types.Config) {
+			cfg.SingleServer = false
+			cfg.Endpoints = []types.Endpoint{
+				{URL: "ws://127.0.0.1:1" + xiaoyitest.Path},
+				{URL: srv.URL(), Role: types.RoleBackup},
+			}
+			cfg.Standby = &types.StandbyConfig{FailoverAfter: 1, FailbackAfter: time.Millisecond}
+		})
+		ctx := testContext(t)
+		if err := m.Connect(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// 切回 primary 的定时器不断重试失败，Close 必须等它结束且之后不再重新计时
+		time.Sleep(20 * time.Millisecond)
+		m.Close()
+		srv.Close()
+	}
+}
+
+// flakyTransport 在 failing 为真时拒绝所有拨号
+type flakyTransport struct {
+	types.Transport
+	failing atomic.Bool
+}
+
+func (t *flakyTransport) Dial(ctx context.Context, ep types.Endpoint, header http.Header, onPong func()) (types.Conn, error) {
+	if t.failing.Load() {
+		return nil, errors.New("dial refused")
+	}
+	return t.Transport.Dial(ctx, ep, header, onPong)
+}
+
+func TestManyEndpointsReconnect(t *testing.T) {
+	srv := xiaoyitest.NewServer(testAK, testSK, testAgent)
+	defer srv.Close()
+	const n = 16
+	tr := &flakyTransport{Transport: srv.Transport()}
+	m := newManager(t, srv, func(cfg *types.Config) {
+		cfg.SingleServer = false
+		cfg.Transport = tr
+		for i := 0; i < n; i++ {
+			cfg.Endpoints = append(cfg.Endpoints, types.Endpoint{URL: srv.URL()})
+		}
+	})
+	ctx := testContext(t)
+	if err := m.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitInits(t, ctx, srv, n)
+
+	// 所有接入点同时断开且重连持续失败，重连事件不能互相阻塞而卡住重连与读循环
+	tr.failing.Store(true)
+	srv.Disconnect()
+	time.Sleep(100 * time.Millisecond)
+	tr.failing.Store(false)
+
+	waitInits(t, ctx, srv, 2*n)
+	for srv.Connections() < n {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("%d of %d endpoints reconnected", srv.Connections(), n)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+type methodFunc func(msg *types.A2ARequest, source types.ServerID)
+
+// MethodHandler 处理自定义 JSON-RPC 方法，返回值作为 result 回复，result 与 error 均为 nil 时不回复；
+// 返回 *types.JsonRpcError 或 *types.XiaoYiError 时以其 Code 作为错误码，其他错误按 Internal error 回复
+type MethodHandler func(ctx context.Context, msg *types.A2ARequest) (any, error)
+
+// OnMethod 注册 JSON-RPC 方法处理器，可覆盖内置方法
+func (m *Manager) OnMethod(name string, h MethodHandler) {
+	m.methodsMu.Lock()
+	defer m.methodsMu.Unlock()
+	if h == nil {
+		delete(m.methods, name)
+		return
+	}
+	m.methods[name] = func(msg *types.A2ARequest, source types.ServerID) {
+		m.callMethod(msg, source, h)
+	}
+}
+
+func (m *Manager) callMethod(msg *types.A2ARequest, source types.ServerID, h MethodHandler) {
+	sessionID := msg.SessionID()
+	m.dispatch(msg, source, func() {
+		result, err := h(m.baseCtx, msg)
+		if err != nil {
+			var re *types.JsonRpcError
+			var xe *types.XiaoYiError
+			switch {
+			case errors.As(err, &re):
+				m.sendRPCError(msg, source, re.Code, re.Message)
+			case errors.As(err, &xe):
+				m.sendRPCError(msg, source, xe.Code, xe.Message)
+			default:
+				m.sendRPCError(msg, source, types.RPCInternalError, err.Error())
+			}
+			return
+		}
+		if result == nil {
+			return
+		}
+		resp := &types.JsonRpcResponse{JSONRPC: "2.0", ID: msg.ID, Result: result}
+		out := protocol.BuildResponseMessage(m.config.AgentID, sessionID, msg.TaskID(), resp)
+		if err := m.checkFrame(out); err != nil {
+			m.sendRPCError(msg, source, types.RPCInternalError, err.Error())
+			return
+		}
+		if err := m.sendTo(source, out); err != nil {
+			slog.Warn("方法响应发送失败", "method", msg.Method, "error", err)
+		}
+	}, nil)
+}
+
+func (m *Manager) handleClearContext(msg *types.A2ARequest, source types.ServerID) {
+	sessionID := msg.SessionID()
+	m.cancelSessionTasks(sessionID, types.ErrContextCleared)
+	if m.handlers.clear != nil {
+		m.handlers.clear(sessionID)
+	}
+	m.sendClearContextResponse(msg.ID, sessionID, true, source)
+	m.sessions.Delete(sessionID)
+}
+
+func (m *Manager) handleTasksCancel(msg *types.A2ARequest, source types.ServerID) {
+	sessionID := msg.SessionID()
+	taskID := msg.Params.ID
+	canceled := m.cancelTask(taskID, types.ErrTaskCanceled)
+	if m.handlers.cancel != nil {
+		m.handlers.cancel(sessionID, taskID)
+	}
+	m.sendTasksCancelResponse(msg.ID, sessionID, taskID, canceled, source)
+}
+
+func (m *Manager) handleUserMessage(msg *types.A2ARequest, source types.ServerID) {
+	if m.handlers.message == nil {
+		return
+	}
+	sessionID := msg.SessionID()
+	ctx, task := m.startTask(sessionID, msg.TaskID())
+	m.dispatch(msg, source, func() {
+		defer m.releaseTask(msg.TaskID(), task)
+		if ctx.Err() != nil {
+			slog.Debug("任务在处理前已取消", "task", msg.TaskID(), "cause", context.Cause(ctx))
+			return
+		}
+		m.handlers.message(ctx, msg)
+	}, func() {
+		m.finishTask(msg.TaskID(), task)
+	})
+}
+
+func (m *Manager) sendRPCError(msg *types.A2ARequest, target types.ServerID, code any, message string) {
+	resp := protocol.BuildErrorResponse(msg.ID, code, message)
+	out := protocol.BuildResponseMessage(m.config.AgentID, msg.SessionID(), msg.TaskID(), resp)
+	if err := m.sendTo(target, out); err != nil {
+		slog.Warn("错误响应发送失败", "method", msg.Method, "error", err)
+	}
+}
@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// PipeConn 是内存中的一端连接，由 Pipe 成对创建，用于在不经过网络的情况下驱动完整协议
+type PipeConn struct {
+	peer   *PipeConn
+	onPong func()
+
+	mu         sync.Mutex
+	frames     [][]byte
+	closed     bool
+	peerClosed bool
+	notify     chan struct{}
+}
+
+// Pipe 返回一对互相连接的 PipeConn，一端写入的帧按顺序从另一端读出
+func Pipe() (*PipeConn, *PipeConn) {
+	a := &PipeConn{notify: make(chan struct{}, 1)}
+	b := &PipeConn{notify: make(chan struct{}, 1)}
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+// OnPong 设置本端 Ping 成功后的回调，对端视为总是立即回复 pong
+func (c *PipeConn) OnPong(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onPong = fn
+}
+
+// ReadMessage 读取对端写入的下一帧，对端关闭且数据读完后返回 io.EOF
+func (c *PipeConn) ReadMessage() ([]byte, error) {
+	for {
+		c.mu.Lock()
+		switch {
+		case c.closed:
+			c.mu.Unlock()
+			return nil, net.ErrClosed
+		case len(c.frames) > 0:
+			data := c.frames[0]
+			c.frames = c.frames[1:]
+			c.mu.Unlock()
+			return data, nil
+		case c.peerClosed:
+			c.mu.Unlock()
+			return nil, io.EOF
+		}
+		c.mu.Unlock()
+		<-c.notify
+	}
+}
+
+func (c *PipeConn) WriteMessage(data []byte) error {
+	c.mu.Lock()
+	closed := c.closed || c.peerClosed
+	c.mu.Unlock()
+	if closed {
+		return net.ErrClosed
+	}
+	c.peer.deliver(append([]byte(nil), data...))
+	return nil
+}
+
+func (c *PipeConn) Ping() error {
+	c.mu.Lock()
+	closed := c.closed || c.peerClosed
+	onPong := c.onPong
+	c.mu.Unlock()
+	if closed {
+		return net.ErrClosed
+	}
+	if onPong != nil {
+		onPong()
+	}
+	return nil
+}
+
+// Close 关闭本端，对端读完剩余数据后收到 io.EOF
+func (c *PipeConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.signal()
+
+	c.peer.mu.Lock()
+	c.peer.peerClosed = true
+	c.peer.mu.Unlock()
+	c.peer.signal()
+	return nil
+}
+
+func (c *PipeConn) deliver(data []byte) {
+	c.mu.Lock()
+	c.frames = append(c.frames, data)
+	c.mu.Unlock()
+	c.signal()
+}
+
+func (c *PipeConn) signal() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
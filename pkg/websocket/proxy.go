@@ -0,0 +1,222 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// proxyDialer 经代理建立到 addr 的 TCP 隧道，支持 HTTP CONNECT（http/https 代理）与 SOCKS5
+type proxyDialer struct {
+	proxy   *url.URL
+	forward net.Dialer
+}
+
+func (d *proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.dialProxy(ctx)
+	if err != nil {
+		return nil, proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tunnel := conn
+	switch d.proxy.Scheme {
+	case "socks5", "socks5h":
+		err = d.socks5(conn, addr)
+	default:
+		tunnel, err = d.connect(conn, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+func (d *proxyDialer) dialProxy(ctx context.Context) (net.Conn, error) {
+	host := d.proxy.Host
+	if d.proxy.Port() == "" {
+		host = net.JoinHostPort(d.proxy.Hostname(), defaultProxyPort(d.proxy.Scheme))
+	}
+	if d.proxy.Scheme != "https" {
+		return d.forward.DialContext(ctx, "tcp", host)
+	}
+	td := tls.Dialer{NetDialer: &d.forward, Config: &tls.Config{ServerName: d.proxy.Hostname(), MinVersion: tls.VersionTLS12}}
+	return td.DialContext(ctx, "tcp", host)
+}
+
+func defaultProxyPort(scheme string) string {
+	switch scheme {
+	case "https":
+		return "443"
+	case "socks5", "socks5h":
+		return "1080"
+	}
+	return "80"
+}
+
+// connect 发送 HTTP CONNECT 请求，407 视为代理认证失败
+func (d *proxyDialer) connect(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := d.proxy.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired && d.proxy.User == nil:
+		return nil, proxyError(types.ErrProxyAuthRequired, d.proxy, fmt.Errorf("CONNECT %s: %s", addr, resp.Status))
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return nil, proxyError(types.ErrProxyAuthFailed, d.proxy, fmt.Errorf("CONNECT %s: %s", addr, resp.Status))
+	case resp.StatusCode != http.StatusOK:
+		return nil, proxyError(types.ErrProxyFailed, d.proxy, fmt.Errorf("CONNECT %s: %s", addr, resp.Status))
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// socks5 按 RFC 1928/1929 完成握手与 CONNECT，目标主机名交给代理解析
+func (d *proxyDialer) socks5(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+
+	methods := []byte{0x00}
+	if d.proxy.User != nil {
+		methods = []byte{0x00, 0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+	if reply[0] != 0x05 {
+		return proxyError(types.ErrProxyFailed, d.proxy, fmt.Errorf("unexpected SOCKS version %d", reply[0]))
+	}
+
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if d.proxy.User == nil {
+			return proxyError(types.ErrProxyAuthRequired, d.proxy, fmt.Errorf("SOCKS5 username/password required"))
+		}
+		user := d.proxy.User.Username()
+		password, _ := d.proxy.User.Password()
+		if len(user) > 255 || len(password) > 255 {
+			return proxyError(types.ErrProxyAuthFailed, d.proxy, fmt.Errorf("SOCKS5 credentials too long"))
+		}
+		msg := []byte{0x01, byte(len(user))}
+		msg = append(msg, user...)
+		msg = append(msg, byte(len(password)))
+		msg = append(msg, password...)
+		if _, err := conn.Write(msg); err != nil {
+			return proxyError(types.ErrProxyFailed, d.proxy, err)
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return proxyError(types.ErrProxyFailed, d.proxy, err)
+		}
+		if reply[1] != 0x00 {
+			return proxyError(types.ErrProxyAuthFailed, d.proxy, fmt.Errorf("SOCKS5 authentication rejected"))
+		}
+	case 0xff:
+		return proxyError(types.ErrProxyAuthRequired, d.proxy, fmt.Errorf("SOCKS5 no acceptable authentication method"))
+	default:
+		return proxyError(types.ErrProxyFailed, d.proxy, fmt.Errorf("unsupported SOCKS5 method %d", reply[1]))
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(append(req, 0x01), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, 0x04), ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return proxyError(types.ErrProxyFailed, d.proxy, fmt.Errorf("host name too long: %s", host))
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+	if head[1] != 0x00 {
+		return proxyError(types.ErrProxyFailed, d.proxy, fmt.Errorf("SOCKS5 CONNECT %s: reply code %d", addr, head[1]))
+	}
+	// 丢弃代理返回的绑定地址
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return proxyError(types.ErrProxyFailed, d.proxy, err)
+		}
+		skip = int(n[0])
+	default:
+		return proxyError(types.ErrProxyFailed, d.proxy, fmt.Errorf("unexpected SOCKS5 address type %d", head[3]))
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip+2)); err != nil {
+		return proxyError(types.ErrProxyFailed, d.proxy, err)
+	}
+	return nil
+}
+
+// proxyError 生成带代理地址的错误，地址中不包含凭据
+func proxyError(base *types.XiaoYiError, proxy *url.URL, err error) error {
+	return &types.XiaoYiError{Code: base.Code, Message: base.Message + ": " + proxy.Redacted(), Err: err}
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
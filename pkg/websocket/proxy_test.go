@@ -0,0 +1,292 @@
+package websocket_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/websocket"
+)
+
+const (
+	proxyUser     = "agent"
+	proxyPassword = "secret"
+)
+
+// newEchoServer 返回一个把收到的帧原样写回的 WebSocket 服务
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+	var upgrader gorilla.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func relay(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// newConnectProxy 启动要求 Basic 认证的 HTTP CONNECT 代理，tunnels 统计建立的隧道数
+func newConnectProxy(t *testing.T, tunnels *atomic.Int32) string {
+	t.Helper()
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte(proxyUser+":"+proxyPassword))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != want {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		tunnels.Add(1)
+		relay(conn, upstream)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// newSOCKS5Proxy 启动只接受用户名密码认证的 SOCKS5 代理
+func newSOCKS5Proxy(t *testing.T, tunnels *atomic.Int32) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, tunnels)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveSOCKS5(conn net.Conn, tunnels *atomic.Int32) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return
+	}
+	if !strings.ContainsRune(string(methods), 0x02) {
+		conn.Write([]byte{0x05, 0xff})
+		return
+	}
+	conn.Write([]byte{0x05, 0x02})
+
+	readString := func() string {
+		n, _ := r.ReadByte()
+		b := make([]byte, n)
+		io.ReadFull(r, b)
+		return string(b)
+	}
+	r.ReadByte() // 子协商版本
+	user, password := readString(), readString()
+	if user != proxyUser || password != proxyPassword {
+		conn.Write([]byte{0x01, 0x01})
+		return
+	}
+	conn.Write([]byte{0x01, 0x00})
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(r, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 0x01:
+		ip := make([]byte, net.IPv4len)
+		io.ReadFull(r, ip)
+		host = net.IP(ip).String()
+	case 0x03:
+		host = readString()
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	io.ReadFull(r, port)
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+	tunnels.Add(1)
+	relay(&bufferedConn{Conn: conn, r: r}, upstream)
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func dialVia(t *testing.T, target string, proxy *types.ProxyConfig) error {
+	t.Helper()
+	tr := &websocket.GorillaTransport{Proxy: proxy.ProxyURL, HandshakeTimeout: 3 * time.Second}
+	conn, err := tr.Dial(context.Background(), types.Endpoint{ID: "test", URL: target}, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage([]byte("ping")); err != nil {
+		return err
+	}
+	data, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if string(data) != "ping" {
+		t.Fatalf("echo = %q", data)
+	}
+	return nil
+}
+
+func checkProxyDial(t *testing.T, target string, tunnels *atomic.Int32, proxy *types.ProxyConfig, want *types.XiaoYiError) {
+	t.Helper()
+	before := tunnels.Load()
+	err := dialVia(t, target, proxy)
+	if want == nil {
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		if tunnels.Load() == before {
+			t.Fatal("connection did not go through the proxy")
+		}
+		return
+	}
+	if !errors.Is(err, want) {
+		t.Fatalf("err = %v, want %s", err, want.Code)
+	}
+	if strings.Contains(err.Error(), proxyPassword) {
+		t.Fatalf("error leaks proxy password: %v", err)
+	}
+}
+
+func TestHTTPConnectProxy(t *testing.T) {
+	target := newEchoServer(t)
+	var tunnels atomic.Int32
+	addr := newConnectProxy(t, &tunnels)
+
+	tests := []struct {
+		name  string
+		proxy *types.ProxyConfig
+		want  *types.XiaoYiError
+	}{
+		{"credentials in URL", &types.ProxyConfig{URL: "http://" + proxyUser + ":" + proxyPassword + "@" + addr}, nil},
+		{"credentials fields", &types.ProxyConfig{URL: addr, Username: proxyUser, Password: proxyPassword}, nil},
+		{"missing credentials", &types.ProxyConfig{URL: "http://" + addr}, types.ErrProxyAuthRequired},
+		{"wrong password", &types.ProxyConfig{URL: "http://" + addr, Username: proxyUser, Password: "wrong"}, types.ErrProxyAuthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkProxyDial(t, target, &tunnels, tt.proxy, tt.want)
+		})
+	}
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	target := newEchoServer(t)
+	var tunnels atomic.Int32
+	addr := newSOCKS5Proxy(t, &tunnels)
+
+	tests := []struct {
+		name  string
+		proxy *types.ProxyConfig
+		want  *types.XiaoYiError
+	}{
+		{"socks5", &types.ProxyConfig{URL: "socks5://" + addr, Username: proxyUser, Password: proxyPassword}, nil},
+		{"socks5h", &types.ProxyConfig{URL: "socks5h://" + proxyUser + ":" + proxyPassword + "@" + addr}, nil},
+		{"missing credentials", &types.ProxyConfig{URL: "socks5://" + addr}, types.ErrProxyAuthRequired},
+		{"wrong password", &types.ProxyConfig{URL: "socks5://" + addr, Username: proxyUser, Password: "wrong"}, types.ErrProxyAuthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkProxyDial(t, target, &tunnels, tt.proxy, tt.want)
+		})
+	}
+}
+
+func TestNoProxyBypassesProxy(t *testing.T) {
+	target := newEchoServer(t)
+	var tunnels atomic.Int32
+	addr := newSOCKS5Proxy(t, &tunnels)
+
+	proxy := &types.ProxyConfig{URL: "socks5://" + addr, NoProxy: []string{"127.0.0.0/8"}}
+	if err := dialVia(t, target, proxy); err != nil {
+		t.Fatal(err)
+	}
+	if tunnels.Load() != 0 {
+		t.Fatal("NoProxy host went through the proxy")
+	}
+}
+
+func TestUnreachableProxy(t *testing.T) {
+	target := newEchoServer(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	err = dialVia(t, target, &types.ProxyConfig{URL: "http://" + addr})
+	if !errors.Is(err, types.ErrProxyFailed) {
+		t.Fatalf("err = %v, want %s", err, types.ErrProxyFailed.Code)
+	}
+}
@@ -0,0 +1,238 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+type waitFlushedKey struct{}
+
+// WithWaitFlushed 使发送在帧进入重连队列时阻塞，直到帧被实际写出、过期或 ctx 结束
+func WithWaitFlushed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, waitFlushedKey{}, true)
+}
+
+func waitFlushed(ctx context.Context) bool {
+	wait, _ := ctx.Value(waitFlushedKey{}).(bool)
+	return wait
+}
+
+type queuedFrame struct {
+	msg  *types.OutboundMessage
+	size int
+	at   time.Time
+	done chan error
+}
+
+// outboundQueue 在服务器重连期间暂存待发送的帧，重连成功后按入队顺序发送
+type outboundQueue struct {
+	maxAge   time.Duration
+	maxBytes int
+	live     func() bool // 连接是否已建立
+	onExpire func(f *queuedFrame, err error)
+
+	mu       sync.Mutex
+	frames   []*queuedFrame
+	bytes    int
+	flushing bool
+	timer    *time.Timer
+}
+
+func newOutboundQueue(cfg *types.QueueConfig, live func() bool, onExpire func(f *queuedFrame, err error)) *outboundQueue {
+	return &outboundQueue{
+		maxAge:   cfg.MaxAge,
+		maxBytes: cfg.MaxBytes,
+		live:     live,
+		onExpire: onExpire,
+	}
+}
+
+// pending 表示队列中仍有帧未发送，此时新帧也需入队以保证顺序
+func (q *outboundQueue) pending() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.frames) > 0 || q.flushing
+}
+
+// push 把帧加入队尾；连接已建立且没有正在进行的发送时返回 flush=true，由调用方负责发送队列
+func (q *outboundQueue) push(msg *types.OutboundMessage) (f *queuedFrame, flush bool, err error) {
+	data, err := protocol.Marshal(msg)
+	if err != nil {
+		return nil, false, err
+	}
+
+	q.mu.Lock()
+	expired := q.expireLocked(time.Now())
+	f = &queuedFrame{msg: msg, size: len(data), at: time.Now(), done: make(chan error, 1)}
+	if q.bytes+f.size > q.maxBytes {
+		q.mu.Unlock()
+		q.report(expired)
+		return nil, false, types.ErrQueueFull
+	}
+	q.frames = append(q.frames, f)
+	q.bytes += f.size
+	if q.timer == nil {
+		q.timer = time.AfterFunc(q.maxAge, q.sweep)
+	}
+	// 发送方在最后一次 take 之后才入队时，连接仍在但已无人发送
+	if !q.flushing && q.live() {
+		q.flushing = true
+		flush = true
+	}
+	q.mu.Unlock()
+	q.report(expired)
+	return f, flush, nil
+}
+
+// claim 标记队列进入发送状态，已有发送在进行时返回 false
+func (q *outboundQueue) claim() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.flushing {
+		return false
+	}
+	q.flushing = true
+	return true
+}
+
+// take 取出全部待发送帧，队列为空时结束发送状态；判断与结束在同一把锁内，避免与 push 竞争
+func (q *outboundQueue) take() []*queuedFrame {
+	q.mu.Lock()
+	expired := q.expireLocked(time.Now())
+	frames := q.frames
+	q.frames = nil
+	q.bytes = 0
+	if len(frames) == 0 {
+		q.flushing = false
+	}
+	q.mu.Unlock()
+	q.report(expired)
+	return frames
+}
+
+// requeue 把未能发送的帧放回队首并结束发送状态；取出期间过期清理可能已因队列为空停止，需重新计时
+func (q *outboundQueue) requeue(frames []*queuedFrame) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.frames = append(frames, q.frames...)
+	for _, f := range frames {
+		q.bytes += f.size
+	}
+	q.flushing = false
+	if q.timer == nil && len(q.frames) > 0 {
+		q.timer = time.AfterFunc(q.maxAge-time.Since(q.frames[0].at), q.sweep)
+	}
+}
+
+func (q *outboundQueue) sweep() {
+	q.mu.Lock()
+	q.timer = nil
+	expired := q.expireLocked(time.Now())
+	if len(q.frames) > 0 {
+		q.timer = time.AfterFunc(q.maxAge-time.Since(q.frames[0].at), q.sweep)
+	}
+	q.mu.Unlock()
+	q.report(expired)
+}
+
+func (q *outboundQueue) expireLocked(now time.Time) []*queuedFrame {
+	n := 0
+	for n < len(q.frames) && now.Sub(q.frames[n].at) >= q.maxAge {
+		q.bytes -= q.frames[n].size
+		n++
+	}
+	expired := q.frames[:n:n]
+	q.frames = q.frames[n:]
+	return expired
+}
+
+func (q *outboundQueue) report(expired []*queuedFrame) {
+	for _, f := range expired {
+		err := &types.XiaoYiError{
+			Code:    types.ErrFrameExpired.Code,
+			Message: fmt.Sprintf("frame for task %s expired after %s in outbound queue", f.msg.TaskID, q.maxAge),
+		}
+		f.done <- err
+		q.onExpire(f, err)
+	}
+}
+
+func (m *Manager) queue(id types.ServerID) *outboundQueue {
+	if s := m.conn(id); s != nil {
+		return s.queue
+	}
+	return nil
+}
+
+// write 直接写出帧，目标服务器的队列中仍有帧时视为未就绪
+func (m *Manager) write(id types.ServerID, msg *types.OutboundMessage) error {
+	if q := m.queue(id); q != nil && q.pending() {
+		return types.ErrServerNotReady
+	}
+	return m.sendTo(id, msg)
+}
+
+// enqueue 把帧放入服务器的重连队列，ctx 带有 WithWaitFlushed 时等待帧被写出
+func (m *Manager) enqueue(ctx context.Context, id types.ServerID, msg *types.OutboundMessage, cause error) error {
+	q := m.queue(id)
+	if q == nil {
+		return cause
+	}
+	select {
+	case <-m.done:
+		return types.ErrClientClosed
+	default:
+	}
+	f, flush, err := q.push(msg)
+	if err != nil {
+		return err
+	}
+	m.metrics.queued.Add(1)
+	slog.Debug("帧进入重连队列", "server", id, "task", msg.TaskID, "cause", cause)
+	if flush {
+		m.drainQueue(id, q)
+	}
+	if !waitFlushed(ctx) {
+		return nil
+	}
+	select {
+	case err := <-f.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flushQueue 在连接建立后按顺序发送队列中的帧，发送失败的帧留待下次重连
+func (m *Manager) flushQueue(id types.ServerID) {
+	q := m.queue(id)
+	if q == nil || !q.claim() {
+		return
+	}
+	m.drainQueue(id, q)
+}
+
+// drainQueue 发送队列直到为空，调用方须已持有发送状态
+func (m *Manager) drainQueue(id types.ServerID, q *outboundQueue) {
+	for {
+		frames := q.take()
+		if len(frames) == 0 {
+			return
+		}
+		for i, f := range frames {
+			if err := m.sendTo(id, f.msg); err != nil {
+				slog.Warn("队列帧发送失败", "server", id, "error", err)
+				q.requeue(frames[i:])
+				return
+			}
+			f.done <- nil
+		}
+		slog.Info("重连队列已发送", "server", id, "frames", len(frames))
+	}
+}
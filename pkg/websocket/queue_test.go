@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+func newTestQueue(live bool) *outboundQueue {
+	cfg := &types.QueueConfig{MaxAge: time.Minute, MaxBytes: 1 << 20}
+	return newOutboundQueue(cfg, func() bool { return live }, func(*queuedFrame, error) {})
+}
+
+func TestPushAfterLastTakeStartsFlush(t *testing.T) {
+	q := newTestQueue(true)
+	if !q.claim() {
+		t.Fatal("claim on idle queue failed")
+	}
+	if frames := q.take(); len(frames) != 0 {
+		t.Fatalf("take = %d frames, want 0", len(frames))
+	}
+
+	_, flush, err := q.push(&types.OutboundMessage{TaskID: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !flush {
+		t.Fatal("push on live connection without flusher must start a flush")
+	}
+	if q.claim() {
+		t.Fatal("claim succeeded while a flush is running")
+	}
+	if frames := q.take(); len(frames) != 1 {
+		t.Fatalf("take = %d frames, want 1", len(frames))
+	}
+}
+
+func TestPushWhileFlushingJoinsRunningFlush(t *testing.T) {
+	q := newTestQueue(true)
+	q.claim()
+
+	_, flush, err := q.push(&types.OutboundMessage{TaskID: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flush {
+		t.Fatal("push must not start a second flush")
+	}
+	if frames := q.take(); len(frames) != 1 {
+		t.Fatalf("take = %d frames, want 1", len(frames))
+	}
+	if frames := q.take(); len(frames) != 0 || q.pending() {
+		t.Fatal("queue should be idle after draining")
+	}
+}
+
+func TestPushWhileDisconnectedWaitsForReconnect(t *testing.T) {
+	q := newTestQueue(false)
+	_, flush, err := q.push(&types.OutboundMessage{TaskID: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flush {
+		t.Fatal("push without connection must not flush")
+	}
+	if !q.pending() || !q.claim() {
+		t.Fatal("frame should stay queued until the reconnect flush")
+	}
+}
+
+func TestRequeueAfterSweepRearmsTimer(t *testing.T) {
+	expired := make(chan *queuedFrame, 1)
+	cfg := &types.QueueConfig{MaxAge: 20 * time.Millisecond, MaxBytes: 1 << 20}
+	q := newOutboundQueue(cfg, func() bool { return false }, func(f *queuedFrame, _ error) { expired <- f })
+
+	if _, _, err := q.push(&types.OutboundMessage{TaskID: "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	q.claim()
+	frames := q.take()
+
+	// 取出后队列为空，过期清理触发并停止计时；发送失败放回的帧仍须按时过期
+	time.Sleep(2 * cfg.MaxAge)
+	q.requeue(frames)
+
+	select {
+	case f := <-expired:
+		if f.msg.TaskID != "t1" {
+			t.Fatalf("expired %s, want t1", f.msg.TaskID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("requeued frame never expired")
+	}
+}
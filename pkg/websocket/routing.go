@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// RouteHandler 在回复未经会话绑定的服务器送达时调用
+type RouteHandler func(sessionID string, bound, delivered types.ServerID)
+
+func (m *Manager) OnRoute(h RouteHandler) {
+	m.handlers.route = h
+}
+
+// route 按配置的路由策略投递消息，返回最终送达的服务器；所有服务器都不可用时放入绑定服务器的重连队列
+func (m *Manager) route(ctx context.Context, sessionID string, bound types.ServerID, msg *types.OutboundMessage) (types.ServerID, error) {
+	var delivered types.ServerID
+	var err error
+	switch m.config.Routing {
+	case types.RouteFailover:
+		delivered, err = m.routeFailover(bound, msg)
+	case types.RouteBroadcast:
+		delivered, err = m.routeBroadcast(bound, msg)
+	default:
+		delivered, err = bound, m.write(bound, msg)
+	}
+	if err != nil {
+		return bound, m.enqueue(ctx, bound, msg, err)
+	}
+	if delivered == bound {
+		return delivered, nil
+	}
+
+	m.metrics.rerouted.Add(1)
+	slog.Info("回复改由其他服务器送达", "session", sessionID, "bound", bound, "delivered", delivered)
+	if m.config.Routing == types.RouteFailover {
+		if err := m.sessions.Set(sessionID, delivered); err != nil {
+			slog.Warn("会话绑定保存失败", "session", sessionID, "error", err)
+		}
+	}
+	if m.handlers.route != nil {
+		m.handlers.route(sessionID, bound, delivered)
+	}
+	return delivered, nil
+}
+
+func (m *Manager) routeFailover(bound types.ServerID, msg *types.OutboundMessage) (types.ServerID, error) {
+	err := m.write(bound, msg)
+	if err == nil {
+		return bound, nil
+	}
+	for _, id := range m.servers() {
+		if id == bound {
+			continue
+		}
+		if m.write(id, msg) == nil {
+			return id, nil
+		}
+	}
+	return "", err
+}
+
+// routeBroadcast 发往所有服务器，优先以绑定的服务器作为送达方
+func (m *Manager) routeBroadcast(bound types.ServerID, msg *types.OutboundMessage) (types.ServerID, error) {
+	var delivered types.ServerID
+	var lastErr error
+	for _, id := range m.servers() {
+		if err := m.write(id, msg); err != nil {
+			lastErr = err
+			continue
+		}
+		if delivered == "" || id == bound {
+			delivered = id
+		}
+	}
+	if delivered == "" {
+		return "", lastErr
+	}
+	return delivered, nil
+}
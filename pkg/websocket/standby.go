@@ -0,0 +1,135 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// FailoverHandler 在主备模式切换接入点时调用
+type FailoverHandler func(event types.FailoverEvent)
+
+func (m *Manager) OnFailover(h FailoverHandler) {
+	m.handlers.failover = h
+}
+
+func (m *Manager) activeConn() *serverConn {
+	m.activeMu.Lock()
+	defer m.activeMu.Unlock()
+	return m.active
+}
+
+// switchActive 把 to 设为当前接入点并报告切换事件
+func (m *Manager) switchActive(from, to *serverConn, kind types.FailoverKind, cause error) {
+	m.activeMu.Lock()
+	m.active = to
+	m.activeMu.Unlock()
+
+	slog.Warn("切换接入点", "kind", kind, "from", from.id, "to", to.id, "cause", cause)
+	if m.handlers.failover != nil {
+		m.handlers.failover(types.FailoverEvent{Kind: kind, From: from.id, To: to.id, Cause: cause})
+	}
+}
+
+// connectStandby 按顺序连接接入点直到成功，成功的接入点成为当前接入点
+func (m *Manager) connectStandby(ctx context.Context) error {
+	var firstErr error
+	for _, s := range m.conns {
+		err := m.connect(ctx, s)
+		if err == nil {
+			m.activeMu.Lock()
+			m.active = s
+			m.activeMu.Unlock()
+			if s != m.conns[0] {
+				m.switchActive(m.conns[0], s, types.Failover, firstErr)
+				m.scheduleFailback(s)
+			}
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// nextConn 返回 s 之后的下一个接入点，末尾回到第一个
+func (m *Manager) nextConn(s *serverConn) *serverConn {
+	for i, c := range m.conns {
+		if c == s {
+			return m.conns[(i+1)%len(m.conns)]
+		}
+	}
+	return m.conns[0]
+}
+
+// standbyTarget 决定主备模式下本次重连的目标：心跳超时或连续重连失败达到阈值时切换到下一个接入点
+func (m *Manager) standbyTarget(s *serverConn, cause error) (*serverConn, bool) {
+	if s != m.activeConn() {
+		return nil, false
+	}
+	if len(m.conns) < 2 {
+		return s, false
+	}
+	failures := s.snapshot().ReconnectCount
+	if !errors.Is(cause, errHeartbeatTimeout) && failures < m.config.Standby.FailoverAfter {
+		return s, false
+	}
+
+	next := m.nextConn(s)
+	s.resetAttempts(false)
+	m.switchActive(s, next, types.Failover, cause)
+	return next, true
+}
+
+// scheduleFailback 在备用接入点稳定运行 FailbackAfter 后尝试切回 primary
+func (m *Manager) scheduleFailback(s *serverConn) {
+	primary := m.conns[0]
+	if s == primary {
+		return
+	}
+	conn := s.current()
+	if conn == nil {
+		return
+	}
+	m.activeMu.Lock()
+	defer m.activeMu.Unlock()
+	if m.closed {
+		return
+	}
+	if m.failback != nil {
+		m.failback.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(m.config.Standby.FailbackAfter, func() {
+		// 在锁内登记到 wg，Close 设置 closed 后的 wg.Wait 一定会等到这次切回结束
+		m.activeMu.Lock()
+		if m.closed || m.failback != t {
+			m.activeMu.Unlock()
+			return
+		}
+		m.failback = nil
+		m.wg.Add(1)
+		m.activeMu.Unlock()
+		defer m.wg.Done()
+
+		// 期间切换过接入点或连接已重建时由新的连接重新计时
+		if m.activeConn() != s || s.current() != conn {
+			return
+		}
+		if err := m.connect(m.baseCtx, primary); err != nil {
+			slog.Info("primary 仍不可用，稍后重试切回", "server", primary.id, "error", err)
+			m.scheduleFailback(s)
+			return
+		}
+		m.switchActive(s, primary, types.Failback, nil)
+		// 先切换再断开，readLoop 发现连接已解除绑定后不会触发重连
+		if s.detach(conn) && m.handlers.state != nil {
+			m.handlers.state(s.id, false)
+		}
+	})
+	m.failback = t
+}
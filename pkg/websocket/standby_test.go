@@ -0,0 +1,138 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/xiaoyitest"
+)
+
+// endpointTransport 按接入点 URL 连到不同的模拟网关，可以让单个接入点不可用并统计拨号次数
+type endpointTransport struct {
+	servers map[string]*xiaoyitest.Server
+
+	mu    sync.Mutex
+	down  map[string]bool
+	dials map[string]int
+}
+
+func newEndpointTransport(servers map[string]*xiaoyitest.Server) *endpointTransport {
+	return &endpointTransport{servers: servers, down: make(map[string]bool), dials: make(map[string]int)}
+}
+
+func (t *endpointTransport) Dial(ctx context.Context, ep types.Endpoint, header http.Header, onPong func()) (types.Conn, error) {
+	t.mu.Lock()
+	t.dials[ep.URL]++
+	down := t.down[ep.URL]
+	t.mu.Unlock()
+	if down {
+		return nil, errors.New("dial " + ep.URL + ": unreachable")
+	}
+	return t.servers[ep.URL].Transport().Dial(ctx, ep, header, onPong)
+}
+
+func (t *endpointTransport) setDown(url string, down bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.down[url] = down
+}
+
+func (t *endpointTransport) dialCount(url string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dials[url]
+}
+
+func waitFailover(t *testing.T, ctx context.Context, events <-chan types.FailoverEvent, kind types.FailoverKind, from, to types.ServerID) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		if ev.Kind != kind || ev.From != from || ev.To != to {
+			t.Fatalf("event = %+v, want %s %s -> %s", ev, kind, from, to)
+		}
+	case <-ctx.Done():
+		t.Fatalf("no %s event", kind)
+	}
+}
+
+func waitConnections(t *testing.T, ctx context.Context, srv *xiaoyitest.Server, n int) {
+	t.Helper()
+	for srv.Connections() != n {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("server has %d connections, want %d", srv.Connections(), n)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestStandbyFailoverAndFailback(t *testing.T) {
+	const primaryURL, backupURL = "mem://primary", "mem://backup"
+	primary := xiaoyitest.NewServer(testAK, testSK, testAgent)
+	defer primary.Close()
+	backup := xiaoyitest.NewServer(testAK, testSK, testAgent)
+	defer backup.Close()
+	tr := newEndpointTransport(map[string]*xiaoyitest.Server{primaryURL: primary, backupURL: backup})
+
+	const failbackAfter = 50 * time.Millisecond
+	m := newManager(t, primary, func(cfg *types.Config) {
+		cfg.SingleServer = false
+		cfg.Transport = tr
+		cfg.Endpoints = []types.Endpoint{
+			{URL: primaryURL},
+			{URL: backupURL, Role: types.RoleBackup},
+		}
+		cfg.Standby = &types.StandbyConfig{FailoverAfter: 1, FailbackAfter: failbackAfter}
+	})
+	echo(m)
+	events := make(chan types.FailoverEvent, 10)
+	m.OnFailover(func(ev types.FailoverEvent) { events <- ev })
+
+	ctx := testContext(t)
+	if err := m.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := backup.Connections(); n != 0 {
+		t.Fatalf("standby endpoint connected %d times while primary is up", n)
+	}
+	roundTrip(t, ctx, primary, "s1", "t1", "主")
+
+	// primary 不可用：重连失败达到阈值后切到备用接入点，流量随之转移
+	tr.setDown(primaryURL, true)
+	primary.Disconnect()
+	waitFailover(t, ctx, events, types.Failover, "server1", "server2")
+	if err := backup.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, ctx, backup, "s2", "t2", "备")
+
+	// primary 仍不可用时切回失败并重新计时，恢复后切回并断开备用连接
+	time.Sleep(3 * failbackAfter)
+	if n := tr.dialCount(primaryURL); n < 3 {
+		t.Fatalf("primary dialed %d times, want failback retries", n)
+	}
+	tr.setDown(primaryURL, false)
+	waitFailover(t, ctx, events, types.Failback, "server2", "server1")
+	waitConnections(t, ctx, primary, 1)
+	waitConnections(t, ctx, backup, 0)
+	roundTrip(t, ctx, primary, "s3", "t3", "切回")
+
+	// 再次切到备用接入点后关闭，切回定时器不能继续拨号
+	tr.setDown(primaryURL, true)
+	primary.Disconnect()
+	waitFailover(t, ctx, events, types.Failover, "server1", "server2")
+	m.Close()
+	dials := tr.dialCount(primaryURL)
+	time.Sleep(3 * failbackAfter)
+	if n := tr.dialCount(primaryURL); n != dials {
+		t.Fatalf("primary dialed %d more times after Close", n-dials)
+	}
+}
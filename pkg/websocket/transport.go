@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// GorillaTransport 是基于 gorilla/websocket 的默认 Transport
+type GorillaTransport struct {
+	HandshakeTimeout time.Duration // 默认 types.ConnectionTimeout
+
+	EnableCompression bool  // 协商 permessage-deflate
+	CompressionLevel  int   // 压缩级别，0 时使用 gorilla/websocket 默认级别
+	ReadLimit         int64 // 单条入站消息的字节数上限，0 表示不限
+
+	// Proxy 返回连接目标地址使用的代理，nil 表示直连；未设置时按环境变量决定
+	Proxy func(target *url.URL) (*url.URL, error)
+}
+
+func (t *GorillaTransport) proxyFor(rawURL string) (*url.URL, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if t.Proxy == nil {
+		return (*types.ProxyConfig)(nil).ProxyURL(target)
+	}
+	return t.Proxy(target)
+}
+
+func (t *GorillaTransport) Dial(ctx context.Context, ep types.Endpoint, header http.Header, onPong func()) (types.Conn, error) {
+	timeout := t.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = types.ConnectionTimeout
+	}
+	dialer := websocket.Dialer{
+		TLSClientConfig:   tlsConfig(ep),
+		HandshakeTimeout:  timeout,
+		EnableCompression: t.EnableCompression,
+	}
+	proxy, err := t.proxyFor(ep.URL)
+	if err != nil {
+		return nil, err
+	}
+	if proxy != nil {
+		dialer.NetDialContext = (&proxyDialer{proxy: proxy}).DialContext
+	}
+
+	conn, _, err := dialer.DialContext(ctx, ep.URL, header)
+	if err != nil {
+		return nil, err
+	}
+	if t.CompressionLevel != 0 {
+		if err := conn.SetCompressionLevel(t.CompressionLevel); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if t.ReadLimit > 0 {
+		conn.SetReadLimit(t.ReadLimit)
+	}
+	conn.SetPongHandler(func(string) error {
+		if onPong != nil {
+			onPong()
+		}
+		return nil
+	})
+	return &gorillaConn{conn: conn}, nil
+}
+
+type gorillaConn struct {
+	conn *websocket.Conn
+}
+
+func (c *gorillaConn) ReadMessage() ([]byte, error) {
+	_, data, err := c.conn.ReadMessage()
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return nil, &types.CloseError{Code: ce.Code, Text: ce.Text}
+	}
+	return data, err
+}
+
+func (c *gorillaConn) WriteMessage(data []byte) error {
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *gorillaConn) Ping() error {
+	return c.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (c *gorillaConn) Close() error {
+	return c.conn.Close()
+}
+
+// tlsConfig 按接入点配置生成 TLS 设置，未配置时使用系统根证书校验
+func tlsConfig(ep types.Endpoint) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ep.TLS == nil {
+		return cfg
+	}
+	cfg.RootCAs = ep.TLS.RootCAs
+	cfg.ServerName = ep.TLS.ServerName
+	cfg.Certificates = ep.TLS.Certificates
+	cfg.InsecureSkipVerify = ep.TLS.InsecureSkipVerify
+	if ep.TLS.MinVersion != 0 {
+		cfg.MinVersion = ep.TLS.MinVersion
+	}
+	if len(ep.TLS.Pins) > 0 {
+		pins := ep.TLS.Pins
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(ep.ID, cs, pins)
+		}
+	}
+	return cfg
+}
+
+// verifyPins 要求已校验的证书链（跳过校验时为服务器发送的证书）中至少一个证书的公钥指纹在 pins 中
+func verifyPins(id types.ServerID, cs tls.ConnectionState, pins []string) error {
+	certs := cs.PeerCertificates
+	if len(cs.VerifiedChains) > 0 {
+		certs = nil
+		for _, chain := range cs.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+	}
+	for _, cert := range certs {
+		if slices.Contains(pins, types.SPKIPin(cert)) {
+			return nil
+		}
+	}
+	return &types.XiaoYiError{Code: types.ErrCertPinMismatch.Code, Message: types.ErrCertPinMismatch.Message + ": " + string(id)}
+}
+
+func isNormalClosure(err error) bool {
+	var ce *types.CloseError
+	return errors.As(err, &ce) && ce.Code == types.CloseNormalClosure
+}
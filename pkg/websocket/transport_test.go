@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+func TestTLSConfigPins(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	pin := types.SPKIPin(srv.Certificate())
+	otherPin := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	tests := []struct {
+		name       string
+		tls        *types.TLSConfig
+		wantErr    bool
+		wantPinErr bool
+	}{
+		{name: "指纹匹配", tls: &types.TLSConfig{RootCAs: roots, ServerName: "example.com", Pins: []string{otherPin, pin}}},
+		{name: "指纹不匹配", tls: &types.TLSConfig{RootCAs: roots, ServerName: "example.com", Pins: []string{otherPin}}, wantErr: true, wantPinErr: true},
+		{name: "主机名不匹配", tls: &types.TLSConfig{RootCAs: roots, ServerName: "wrong.example.org", Pins: []string{pin}}, wantErr: true},
+		{name: "跳过链校验仍校验指纹", tls: &types.TLSConfig{InsecureSkipVerify: true, Pins: []string{otherPin}}, wantErr: true, wantPinErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tlsConfig(types.Endpoint{ID: "server1", TLS: tt.tls})
+			conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), cfg)
+			if err == nil {
+				conn.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dial err = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, types.ErrCertPinMismatch); got != tt.wantPinErr {
+				t.Fatalf("dial err = %v, ErrCertPinMismatch = %v, want %v", err, got, tt.wantPinErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package xiaoyitest
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+// Request 描述一条由模拟网关下发的 JSON-RPC 请求
+type Request struct {
+	ID             string
+	Method         string
+	SessionID      string
+	TaskID         string
+	DeviceID       string
+	ConversationID string
+	MessageID      string
+	Parts          []types.Part
+}
+
+func (r *Request) build(agentID string) map[string]any {
+	params := map[string]any{
+		"sessionId": r.SessionID,
+	}
+	if r.TaskID != "" {
+		params["id"] = r.TaskID
+	}
+	if r.Parts != nil {
+		messageID := r.MessageID
+		if messageID == "" {
+			messageID = protocol.GenerateID()
+		}
+		params["message"] = map[string]any{
+			"kind":      "message",
+			"messageId": messageID,
+			"role":      "user",
+			"parts":     r.Parts,
+		}
+	}
+
+	msg := map[string]any{
+		"jsonrpc":   "2.0",
+		"id":        r.ID,
+		"method":    r.Method,
+		"agentId":   agentID,
+		"sessionId": r.SessionID,
+		"params":    params,
+	}
+	if r.DeviceID != "" {
+		msg["deviceId"] = r.DeviceID
+	}
+	if r.ConversationID != "" {
+		msg["conversationId"] = r.ConversationID
+	}
+	return msg
+}
+
+// Response 是一条解码后的 agent_response，msgDetail 按结果类型解析到对应字段
+type Response struct {
+	SessionID  string
+	TaskID     string
+	ID         string
+	Detail     json.RawMessage
+	Result     json.RawMessage
+	ReceivedAt time.Time
+
+	Artifact     *types.ArtifactUpdate
+	Status       *types.StatusUpdate
+	Push         *types.PushUpdate
+	ClearContext *types.ClearContextResult
+	TasksCancel  *types.TasksCancelResult
+	Task         *types.TaskResult
+	Error        *types.JsonRpcError
+}
+
+// Text 拼接响应中所有文本部分
+func (r *Response) Text() string {
+	var parts []types.Part
+	switch {
+	case r.Artifact != nil:
+		parts = r.Artifact.Artifact.Parts
+	case r.Status != nil:
+		parts = r.Status.Status.Message.Parts
+	case r.Push != nil:
+		for _, a := range r.Push.Artifacts {
+			parts = append(parts, a.Parts...)
+		}
+	}
+	var text string
+	for _, p := range parts {
+		if t, ok := p.(*types.TextPart); ok {
+			text += t.Text
+		}
+	}
+	return text
+}
+
+// Final 表示该响应是否结束了任务
+func (r *Response) Final() bool {
+	switch {
+	case r.Artifact != nil:
+		return r.Artifact.Final
+	case r.Status != nil:
+		return r.Status.Final
+	}
+	return r.Error != nil
+}
+
+// decodeResponse 按 kind 识别流式更新与推送，其余结果按响应 id 对应的请求方法解码；
+// method 为空（id 不是本服务器发出的）时只保留原始 Result
+func decodeResponse(msg *types.OutboundMessage, methodOf func(id string) string) (*Response, error) {
+	var detail struct {
+		ID     string              `json:"id"`
+		Result json.RawMessage     `json:"result"`
+		Error  *types.JsonRpcError `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(msg.MsgDetail), &detail); err != nil {
+		return nil, err
+	}
+
+	resp := &Response{
+		SessionID:  msg.SessionID,
+		TaskID:     msg.TaskID,
+		ID:         detail.ID,
+		Detail:     json.RawMessage(msg.MsgDetail),
+		Result:     detail.Result,
+		ReceivedAt: time.Now(),
+		Error:      detail.Error,
+	}
+	if len(detail.Result) == 0 {
+		return resp, nil
+	}
+
+	var probe struct {
+		Kind   string `json:"kind"`
+		PushID string `json:"pushId"`
+	}
+	if err := json.Unmarshal(detail.Result, &probe); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch {
+	case probe.Kind == "artifact-update":
+		resp.Artifact, err = protocol.DecodeArtifactUpdate(detail.Result)
+	case probe.Kind == "status-update":
+		resp.Status, err = protocol.DecodeStatusUpdate(detail.Result)
+	case probe.PushID != "":
+		resp.Push, err = protocol.DecodePushUpdate(detail.Result)
+	default:
+		switch methodOf(detail.ID) {
+		case "tasks/cancel":
+			resp.TasksCancel = &types.TasksCancelResult{}
+			err = json.Unmarshal(detail.Result, resp.TasksCancel)
+		case "clearContext":
+			resp.ClearContext = &types.ClearContextResult{}
+			err = json.Unmarshal(detail.Result, resp.ClearContext)
+		case "tasks/get":
+			resp.Task, err = protocol.DecodeTaskResult(detail.Result)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,395 @@
+package xiaoyitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/auth"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+	sdkws "github.com/ystyle/xiaoyi-agent-sdk/pkg/websocket"
+)
+
+const Path = "/openclaw/v1/ws/link"
+
+// Server 是本地模拟的小艺网关，协议行为与 websocket.Manager 的期望一致
+type Server struct {
+	agentID string
+	ak      string
+	auth    *auth.Auth
+
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu           sync.Mutex
+	conns        []*serverConn
+	inits        int
+	heartbeats   int
+	authFailures int
+	responses    []*Response
+	methods      map[string]string // 已发送请求的 id 到方法名，用于识别响应类型
+	changed      chan struct{}
+}
+
+// frameConn 是服务端一侧的连接，WebSocket 与内存管道两种方式共用同一套处理逻辑
+type frameConn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(data []byte) error
+	Close() error
+}
+
+type wsConn struct {
+	conn *websocket.Conn
+}
+
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	_, data, err := c.conn.ReadMessage()
+	return data, err
+}
+
+func (c *wsConn) WriteMessage(data []byte) error {
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+type serverConn struct {
+	conn frameConn
+	mu   sync.Mutex
+}
+
+func (c *serverConn) write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(data)
+}
+
+func NewServer(ak, sk, agentID string) *Server {
+	s := &Server{
+		agentID: agentID,
+		ak:      ak,
+		auth:    auth.New(ak, sk, agentID),
+		methods: make(map[string]string),
+		changed: make(chan struct{}),
+		// 与网关一样接受 permessage-deflate 协商
+		upgrader: websocket.Upgrader{EnableCompression: true},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(Path, s.serveWS)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + Path
+}
+
+func (s *Server) Close() {
+	s.Disconnect()
+	s.srv.Close()
+}
+
+// Disconnect 断开当前所有客户端连接，用于模拟网络中断
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.conn.Close()
+	}
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r.Header) {
+		s.mu.Lock()
+		s.authFailures++
+		s.notifyLocked()
+		s.mu.Unlock()
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.serve(&wsConn{conn: conn})
+}
+
+func (s *Server) serve(conn frameConn) {
+	sc := &serverConn{conn: conn}
+	s.mu.Lock()
+	s.conns = append(s.conns, sc)
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	defer s.removeConn(sc)
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.handleFrame(data)
+	}
+}
+
+// Transport 返回直连本服务器的内存 Transport，设置到 Config.Transport 后客户端不经过网络即可完成完整协议交互
+func (s *Server) Transport() types.Transport {
+	return &memoryTransport{s: s}
+}
+
+type memoryTransport struct {
+	s *Server
+}
+
+func (t *memoryTransport) Dial(ctx context.Context, ep types.Endpoint, header http.Header, onPong func()) (types.Conn, error) {
+	if !t.s.authorized(header) {
+		t.s.mu.Lock()
+		t.s.authFailures++
+		t.s.notifyLocked()
+		t.s.mu.Unlock()
+		return nil, fmt.Errorf("dial %s: unauthorized", ep.URL)
+	}
+	client, server := sdkws.Pipe()
+	client.OnPong(onPong)
+	go t.s.serve(server)
+	return client, nil
+}
+
+func (s *Server) authorized(h http.Header) bool {
+	if h.Get("x-access-key") != s.ak || h.Get("x-agent-id") != s.agentID {
+		return false
+	}
+	ts, err := strconv.ParseInt(h.Get("x-ts"), 10, 64)
+	if err != nil {
+		return false
+	}
+	return s.auth.Verify(&auth.Credentials{
+		AK:        h.Get("x-access-key"),
+		Timestamp: ts,
+		Signature: h.Get("x-sign"),
+	})
+}
+
+func (s *Server) removeConn(sc *serverConn) {
+	sc.conn.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, c := range s.conns {
+		if c == sc {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			break
+		}
+	}
+	s.notifyLocked()
+}
+
+func (s *Server) handleFrame(data []byte) {
+	var msg types.OutboundMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch msg.MsgType {
+	case "clawd_bot_init":
+		s.inits++
+	case "heartbeat":
+		s.heartbeats++
+	case "agent_response":
+		resp, err := decodeResponse(&msg, func(id string) string { return s.methods[id] })
+		if err != nil {
+			return
+		}
+		s.responses = append(s.responses, resp)
+	default:
+		return
+	}
+	s.notifyLocked()
+}
+
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) waitFor(ctx context.Context, cond func() bool) error {
+	for {
+		s.mu.Lock()
+		ok := cond()
+		ch := s.changed
+		s.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+		}
+	}
+}
+
+// WaitConnected 等待客户端建立连接并发送 clawd_bot_init
+func (s *Server) WaitConnected(ctx context.Context) error {
+	return s.waitFor(ctx, func() bool {
+		return len(s.conns) > 0 && s.inits > 0
+	})
+}
+
+// WaitHeartbeats 等待收到至少 n 个应用层心跳
+func (s *Server) WaitHeartbeats(ctx context.Context, n int) error {
+	return s.waitFor(ctx, func() bool {
+		return s.heartbeats >= n
+	})
+}
+
+// WaitResponses 等待累计收到至少 n 个 agent_response，返回全部响应
+func (s *Server) WaitResponses(ctx context.Context, n int) ([]*Response, error) {
+	if err := s.waitFor(ctx, func() bool { return len(s.responses) >= n }); err != nil {
+		return s.Responses(), err
+	}
+	return s.Responses(), nil
+}
+
+// WaitResponse 等待第一个满足 match 的响应
+func (s *Server) WaitResponse(ctx context.Context, match func(*Response) bool) (*Response, error) {
+	var found *Response
+	err := s.waitFor(ctx, func() bool {
+		for _, r := range s.responses {
+			if match(r) {
+				found = r
+				return true
+			}
+		}
+		return false
+	})
+	return found, err
+}
+
+func (s *Server) Responses() []*Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*Response, len(s.responses))
+	copy(out, s.responses)
+	return out
+}
+
+// ResponsesFor 返回指定任务的全部响应
+func (s *Server) ResponsesFor(taskID string) []*Response {
+	var out []*Response
+	for _, r := range s.Responses() {
+		if r.TaskID == taskID {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = nil
+	s.heartbeats = 0
+	s.notifyLocked()
+}
+
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+func (s *Server) Inits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inits
+}
+
+func (s *Server) Heartbeats() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heartbeats
+}
+
+func (s *Server) AuthFailures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authFailures
+}
+
+// SendMessage 注入一条 message/stream 请求，返回 JSON-RPC 请求 ID
+func (s *Server) SendMessage(sessionID, taskID, text string, parts ...types.Part) (string, error) {
+	all := make([]types.Part, 0, len(parts)+1)
+	if text != "" {
+		all = append(all, types.NewTextPart(text))
+	}
+	all = append(all, parts...)
+	return s.Send(&Request{
+		Method:    "message/stream",
+		SessionID: sessionID,
+		TaskID:    taskID,
+		Parts:     all,
+	})
+}
+
+func (s *Server) ClearContext(sessionID string) (string, error) {
+	return s.Send(&Request{Method: "clearContext", SessionID: sessionID})
+}
+
+func (s *Server) CancelTask(sessionID, taskID string) (string, error) {
+	return s.Send(&Request{Method: "tasks/cancel", SessionID: sessionID, TaskID: taskID})
+}
+
+// Send 向所有已连接的客户端注入一条请求
+func (s *Server) Send(req *Request) (string, error) {
+	if req.ID == "" {
+		req.ID = protocol.GenerateID()
+	}
+	data, err := json.Marshal(req.build(s.agentID))
+	if err != nil {
+		return "", err
+	}
+	return req.ID, s.SendRaw(data)
+}
+
+// SendRaw 原样发送一帧数据，能解析出 id 与 method 时记录下来用于识别对应的响应
+func (s *Server) SendRaw(data []byte) error {
+	var req struct {
+		ID     string `json:"id"`
+		Method string `json:"method"`
+	}
+	json.Unmarshal(data, &req)
+
+	s.mu.Lock()
+	if req.ID != "" && req.Method != "" {
+		s.methods[req.ID] = req.Method
+	}
+	conns := make([]*serverConn, len(s.conns))
+	copy(conns, s.conns)
+	s.mu.Unlock()
+
+	if len(conns) == 0 {
+		return types.ErrNotConnected
+	}
+	var lastErr error
+	for _, c := range conns {
+		if err := c.write(data); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
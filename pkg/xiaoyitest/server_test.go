@@ -0,0 +1,158 @@
+package xiaoyitest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ystyle/xiaoyi-agent-sdk/internal/protocol"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/auth"
+	"github.com/ystyle/xiaoyi-agent-sdk/pkg/types"
+)
+
+func signedHeader(ak, sk, agentID string) http.Header {
+	h := http.Header{}
+	for k, v := range auth.New(ak, sk, agentID).Headers() {
+		h.Set(k, v)
+	}
+	return h
+}
+
+func TestAuthorization(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr bool
+	}{
+		{name: "签名正确", header: signedHeader("ak", "sk", "agent")},
+		{name: "SK 错误", header: signedHeader("ak", "wrong", "agent"), wantErr: true},
+		{name: "AgentID 错误", header: signedHeader("ak", "sk", "other"), wantErr: true},
+		{name: "缺少签名头", header: http.Header{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer("ak", "sk", "agent")
+			defer srv.Close()
+
+			// WebSocket 与内存 Transport 使用同一套校验
+			ws, _, wsErr := websocket.DefaultDialer.Dial(srv.URL(), tt.header)
+			if wsErr == nil {
+				ws.Close()
+			}
+			conn, memErr := srv.Transport().Dial(context.Background(), types.Endpoint{URL: srv.URL()}, tt.header, func() {})
+			if memErr == nil {
+				conn.Close()
+			}
+
+			if (wsErr != nil) != tt.wantErr || (memErr != nil) != tt.wantErr {
+				t.Fatalf("websocket err = %v, memory err = %v, want error %v", wsErr, memErr, tt.wantErr)
+			}
+			want := 0
+			if tt.wantErr {
+				want = 2
+			}
+			if got := srv.AuthFailures(); got != want {
+				t.Fatalf("AuthFailures = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	text := []types.Part{types.NewTextPart("你好")}
+	tests := []struct {
+		name   string
+		resp   *types.JsonRpcResponse
+		method string
+		check  func(r *Response) bool
+	}{
+		{
+			name:  "artifact",
+			resp:  protocol.BuildArtifactResponse("m1", "t1", text, true, false),
+			check: func(r *Response) bool { return r.Artifact != nil && r.Final() && r.Text() == "你好" },
+		},
+		{
+			name:  "status",
+			resp:  protocol.BuildStatusResponse("m1", "t1", "处理中", "working"),
+			check: func(r *Response) bool { return r.Status != nil && !r.Final() && r.Text() == "处理中" },
+		},
+		{
+			name:  "push",
+			resp:  protocol.BuildPushResponse("m1", "t1", "你好", text),
+			check: func(r *Response) bool { return r.Push != nil && r.Text() == "你好" },
+		},
+		{
+			name:   "tasks/cancel 按请求方法识别",
+			resp:   protocol.BuildTasksCancelResponse("r1", "t1", true),
+			method: "tasks/cancel",
+			check:  func(r *Response) bool { return r.TasksCancel != nil && r.TasksCancel.Status.State == "canceled" },
+		},
+		{
+			name:   "clearContext 按请求方法识别",
+			resp:   protocol.BuildClearContextResponse("r1", true),
+			method: "clearContext",
+			check:  func(r *Response) bool { return r.ClearContext != nil && r.TasksCancel == nil },
+		},
+		{
+			name:  "错误响应结束任务",
+			resp:  protocol.BuildErrorResponse("m1", "ERROR_CODE", "失败"),
+			check: func(r *Response) bool { return r.Error != nil && r.Final() },
+		},
+		{
+			name:  "未知请求只保留原始结果",
+			resp:  protocol.BuildTasksCancelResponse("r1", "t1", true),
+			check: func(r *Response) bool { return r.TasksCancel == nil && len(r.Result) > 0 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := protocol.BuildResponseMessage("agent", "s1", "t1", tt.resp)
+			r, err := decodeResponse(msg, func(string) string { return tt.method })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.SessionID != "s1" || r.TaskID != "t1" || !tt.check(r) {
+				t.Fatalf("decoded %+v", r)
+			}
+		})
+	}
+}
+
+func TestSendWithoutConnection(t *testing.T) {
+	srv := NewServer("ak", "sk", "agent")
+	defer srv.Close()
+	if _, err := srv.SendMessage("s1", "t1", "你好"); !errors.Is(err, types.ErrNotConnected) {
+		t.Fatalf("SendMessage err = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	srv := NewServer("ak", "sk", "agent")
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := srv.Transport().Dial(ctx, types.Endpoint{URL: srv.URL()}, signedHeader("ak", "sk", "agent"), func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	data, _ := protocol.Marshal(protocol.BuildInitMessage("agent"))
+	if err := conn.WriteMessage(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Disconnect()
+	if n := srv.Connections(); n != 0 {
+		t.Fatalf("Connections = %d after Disconnect", n)
+	}
+	if _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("client side still open after Disconnect")
+	}
+}